@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Art-Net 4 Constants
+	ArtNetID              = "Art-Net\000"
+	ArtNetProtocolVersion = uint16(14)
+	ArtNetPort            = 6454
+	OpPoll                = uint16(0x2000)
+	OpPollReply           = uint16(0x2100)
+	OpDmx                 = uint16(0x5000)
+
+	ArtDmxHeaderLength    = 18
+	ArtPollLength         = 22
+	ArtPollReplyLength    = 239
+	ArtPollInterval       = 3 * time.Second
+	ArtNodeTimeout        = 3 * ArtPollInterval
+	ArtPollFlagsTalkToMe  = uint8(0x02) // send ArtPollReply whenever a node's state changes
+	ArtPollReplyStyleCtrl = uint8(0x04) // StController, a lighting console
+)
+
+// ArtNetNode is a node that answered one of our polls
+type ArtNetNode struct {
+	Address       string    `json:"address"`
+	ShortName     string    `json:"shortName"`
+	LongName      string    `json:"longName"`
+	PortAddresses []uint16  `json:"portAddresses"`
+	LastSeen      time.Time `json:"lastSeen"`
+}
+
+// ArtNetTransmitter sends universes as ArtDmx packets. it polls the network for nodes every
+// few seconds and answers polls from other controllers, so consoles and node configuration
+// tools can see it.
+type ArtNetTransmitter struct {
+	conn       *net.UDPConn
+	universes  map[uint16]*ArtNetUniverse
+	handlers   map[uint16]func(data []byte)
+	nodes      map[string]ArtNetNode
+	localAddrs map[string]bool
+	shortName  string
+	longName   string
+	mu         sync.RWMutex
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+type ArtNetUniverse struct {
+	number       uint16 // used as the 15-bit port-address: net, sub-net and universe
+	sequence     uint8
+	destinations []*net.UDPAddr
+	packet       []byte // written in place for every send, the header only once
+	data         []byte // the DMX data, in the packet
+	slot         *FrameSlot
+	lastSent     time.Time
+}
+
+func NewArtNetTransmitter(name string) (*ArtNetTransmitter, error) {
+	// art-net nodes expect packets from the art-net port and send their replies to it. if
+	// another program already has it we can still send, we just won't hear from anyone.
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: ArtNetPort})
+	if err != nil {
+		log.Printf("Warning: art-net port %d unavailable, polls will not be answered: %v", ArtNetPort, err)
+		conn, err = net.ListenUDP("udp", &net.UDPAddr{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create UDP connection: %w", err)
+		}
+	}
+
+	t := &ArtNetTransmitter{
+		conn:       conn,
+		universes:  make(map[uint16]*ArtNetUniverse),
+		handlers:   make(map[uint16]func(data []byte)),
+		nodes:      make(map[string]ArtNetNode),
+		localAddrs: localAddresses(),
+		shortName:  name,
+		longName:   name + " pixel controller",
+		done:       make(chan struct{}),
+	}
+
+	t.wg.Add(3)
+	go t.keepAliveLoop()
+	go t.pollLoop()
+	go t.receiveLoop()
+
+	return t, nil
+}
+
+func (t *ArtNetTransmitter) Protocol() string {
+	return PROTOCOL_ARTNET
+}
+
+func (t *ArtNetTransmitter) Activate(universeNumber uint16) (*FrameSlot, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if universeNumber > 0x7FFF {
+		return nil, fmt.Errorf("universe %d is beyond the art-net port-address range", universeNumber)
+	}
+	if _, exists := t.universes[universeNumber]; exists {
+		return nil, fmt.Errorf("universe %d already activated", universeNumber)
+	}
+
+	packet := make([]byte, ArtDmxHeaderLength+UniverseChannels)
+	universe := &ArtNetUniverse{
+		number:       universeNumber,
+		destinations: make([]*net.UDPAddr, 0),
+		packet:       packet,
+		data:         packet[ArtDmxHeaderLength:],
+		slot:         NewFrameSlot(),
+	}
+	writeDmxHeader(universe)
+
+	t.universes[universeNumber] = universe
+
+	t.wg.Add(1)
+	go t.handleUniverse(universe)
+
+	return universe.slot, nil
+}
+
+// art-net has no notion of priority, so it's ignored
+func (t *ArtNetTransmitter) SetDestination(universe uint16, addr string, priority uint8) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", addr, ArtNetPort))
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, exists := t.universes[universe]
+	if !exists {
+		return fmt.Errorf("universe %d not activated", universe)
+	}
+
+	state.destinations = []*net.UDPAddr{udpAddr}
+
+	return nil
+}
+
+func (t *ArtNetTransmitter) AddDestination(universe uint16, addr string, priority uint8) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", addr, ArtNetPort))
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, exists := t.universes[universe]
+	if !exists {
+		return fmt.Errorf("universe %d not activated", universe)
+	}
+
+	state.destinations = append(state.destinations, udpAddr)
+
+	return nil
+}
+
+// Nodes returns the nodes that have answered a poll recently
+func (t *ArtNetTransmitter) Nodes() []ArtNetNode {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	nodes := make([]ArtNetNode, 0, len(t.nodes))
+	for _, node := range t.nodes {
+		if time.Since(node.LastSeen) < ArtNodeTimeout {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Address < nodes[j].Address })
+	return nodes
+}
+
+func (t *ArtNetTransmitter) handleUniverse(universe *ArtNetUniverse) {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case <-universe.slot.Ready():
+			t.mu.Lock()
+			if universe.slot.Take(universe.setData) {
+				universe.lastSent = time.Now()
+				t.sendToDestinations(universe)
+			}
+			t.mu.Unlock()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// writes the parts of a universe's ArtDmx packet that never change, once when it's activated
+func writeDmxHeader(universe *ArtNetUniverse) {
+	packet := universe.packet
+	copy(packet[0:8], ArtNetID)
+	binary.LittleEndian.PutUint16(packet[8:10], OpDmx)
+	binary.BigEndian.PutUint16(packet[10:12], ArtNetProtocolVersion)
+	packet[13] = 0                               // Physical input port, informational only
+	packet[14] = byte(universe.number & 0xFF)    // SubUni: sub-net and universe
+	packet[15] = byte(universe.number>>8) & 0x7F // Net
+}
+
+// fills in the rest of the universe's packet around the data already in it
+func (t *ArtNetTransmitter) createDmxPacket(universe *ArtNetUniverse) []byte {
+	// the data length has to be even, padded with a zero
+	length := len(universe.data) + len(universe.data)%2
+	if length > len(universe.data) {
+		universe.packet[ArtDmxHeaderLength+len(universe.data)] = 0
+	}
+
+	packet := universe.packet
+	packet[12] = universe.sequence
+	binary.BigEndian.PutUint16(packet[16:18], uint16(length))
+
+	return packet[:ArtDmxHeaderLength+length]
+}
+
+// copies a frame into the universe's packet, leaving the buffer free for the controller to
+// write its next frame to
+func (u *ArtNetUniverse) setData(data []byte) {
+	if len(data) > UniverseChannels {
+		log.Printf("Invalid DMX data length for universe %d: %d", u.number, len(data))
+		return
+	}
+	u.data = u.packet[ArtDmxHeaderLength : ArtDmxHeaderLength+copy(u.packet[ArtDmxHeaderLength:], data)]
+}
+
+func (t *ArtNetTransmitter) sendToDestinations(universe *ArtNetUniverse) {
+	if len(universe.data) == 0 || len(universe.data) > 512 {
+		log.Printf("Invalid DMX data length for universe %d: %d", universe.number, len(universe.data))
+		return
+	}
+
+	// sequence 0 tells nodes not to reorder, so it runs from 1 to 255
+	universe.sequence = universe.sequence%0xFF + 1
+
+	packet := t.createDmxPacket(universe)
+
+	for _, dest := range universe.destinations {
+		if _, err := t.conn.WriteToUDP(packet, dest); err != nil {
+			log.Printf("Error sending to art-net universe %d: %v", universe.number, err)
+			continue
+		}
+	}
+}
+
+func (t *ArtNetTransmitter) keepAliveLoop() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(KeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.mu.Lock()
+			now := time.Now()
+			for _, universe := range t.universes {
+				if now.Sub(universe.lastSent) >= KeepAliveInterval {
+					t.sendToDestinations(universe)
+				}
+			}
+			t.mu.Unlock()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// polls the broadcast address and every destination, since directed polls are the only way
+// to reach nodes on networks that drop broadcasts
+func (t *ArtNetTransmitter) pollLoop() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(ArtPollInterval)
+	defer ticker.Stop()
+
+	for {
+		t.mu.RLock()
+		targets := map[string]*net.UDPAddr{
+			"broadcast": {IP: net.IPv4bcast, Port: ArtNetPort},
+		}
+		for _, universe := range t.universes {
+			for _, dest := range universe.destinations {
+				targets[dest.String()] = dest
+			}
+		}
+		t.mu.RUnlock()
+
+		packet := createPollPacket()
+		for _, target := range targets {
+			if _, err := t.conn.WriteToUDP(packet, target); err != nil {
+				log.Printf("Error sending ArtPoll to %v: %v", target, err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func createPollPacket() []byte {
+	packet := make([]byte, ArtPollLength)
+	copy(packet[0:8], ArtNetID)
+	binary.LittleEndian.PutUint16(packet[8:10], OpPoll)
+	binary.BigEndian.PutUint16(packet[10:12], ArtNetProtocolVersion)
+	packet[12] = ArtPollFlagsTalkToMe
+	packet[13] = 0x10 // DiagPriority: low, we don't ask for diagnostics anyway
+	// the remaining target port-address and manufacturer fields are unused without targeted mode
+	return packet
+}
+
+func (t *ArtNetTransmitter) receiveLoop() {
+	defer t.wg.Done()
+
+	buffer := make([]byte, 1024)
+	for {
+		n, addr, err := t.conn.ReadFromUDP(buffer)
+		if err != nil {
+			select {
+			case <-t.done:
+				return
+			default:
+				log.Printf("Error reading art-net packet: %v", err)
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+		}
+
+		packet := buffer[:n]
+		if n < 10 || !bytes.Equal(packet[0:8], []byte(ArtNetID)) {
+			continue
+		}
+
+		opcode := binary.LittleEndian.Uint16(packet[8:10])
+		if t.isOwnPacket(addr, opcode, packet) {
+			continue
+		}
+
+		switch opcode {
+		case OpPoll:
+			t.replyToPoll(addr)
+		case OpPollReply:
+			t.recordNode(packet)
+		case OpDmx:
+			t.receiveDmx(packet)
+		}
+	}
+}
+
+// our own broadcasts come back to us. a console or visualiser on this host sends from the same
+// addresses, and maybe from the art-net port too, so a packet is only ours if it also comes
+// from our port and is what we'd have sent
+func (t *ArtNetTransmitter) isOwnPacket(addr *net.UDPAddr, opcode uint16, packet []byte) bool {
+	if !t.localAddrs[addr.IP.String()] || addr.Port != t.conn.LocalAddr().(*net.UDPAddr).Port {
+		return false
+	}
+
+	switch opcode {
+	case OpPoll:
+		return bytes.Equal(packet, createPollPacket())
+	case OpPollReply:
+		return len(packet) >= 108 &&
+			bytes.Equal(packet[26:43], padString(t.shortName, 17)) &&
+			bytes.Equal(packet[44:107], padString(t.longName, 63))
+	case OpDmx:
+		if len(packet) < ArtDmxHeaderLength {
+			return false
+		}
+		t.mu.RLock()
+		defer t.mu.RUnlock()
+		_, sending := t.universes[uint16(packet[15]&0x7F)<<8|uint16(packet[14])]
+		return sending
+	}
+	return false
+}
+
+// Subscribe has the handler called with the data of every ArtDmx received for the universe
+func (t *ArtNetTransmitter) Subscribe(universe uint16, handler func(data []byte)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.handlers[universe]; exists {
+		return fmt.Errorf("already listening to universe %d", universe)
+	}
+	if t.conn.LocalAddr().(*net.UDPAddr).Port != ArtNetPort {
+		return fmt.Errorf("art-net port %d is in use, we can't receive", ArtNetPort)
+	}
+	t.handlers[universe] = handler
+	return nil
+}
+
+func (t *ArtNetTransmitter) receiveDmx(packet []byte) {
+	if len(packet) < ArtDmxHeaderLength {
+		return
+	}
+	universe := uint16(packet[15]&0x7F)<<8 | uint16(packet[14])
+	length := min(int(binary.BigEndian.Uint16(packet[16:18])), len(packet)-ArtDmxHeaderLength, UniverseChannels)
+
+	t.mu.RLock()
+	handler, exists := t.handlers[universe]
+	t.mu.RUnlock()
+	if exists {
+		handler(packet[ArtDmxHeaderLength : ArtDmxHeaderLength+length])
+	}
+}
+
+func (t *ArtNetTransmitter) replyToPoll(addr *net.UDPAddr) {
+	reply := t.createPollReply(outboundAddress(addr))
+	// replies go to the art-net port of the poller, or its broadcast if it asked for that,
+	// which we don't track. unicast works with everything we've tested.
+	if _, err := t.conn.WriteToUDP(reply, &net.UDPAddr{IP: addr.IP, Port: ArtNetPort}); err != nil {
+		log.Printf("Error sending ArtPollReply to %v: %v", addr.IP, err)
+	}
+}
+
+func (t *ArtNetTransmitter) createPollReply(ip net.IP) []byte {
+	packet := make([]byte, ArtPollReplyLength)
+	copy(packet[0:8], ArtNetID)
+	binary.LittleEndian.PutUint16(packet[8:10], OpPollReply)
+	copy(packet[10:14], ip.To4())
+	binary.LittleEndian.PutUint16(packet[14:16], ArtNetPort)
+	copy(packet[26:44], padString(t.shortName, 17))
+	copy(packet[44:108], padString(t.longName, 63))
+	copy(packet[108:172], padString("#0001 [0000] running", 63)) // NodeReport
+	// NumPorts stays 0: a controller has no ports of its own
+	packet[200] = ArtPollReplyStyleCtrl
+	packet[212] = 0x08 // Status2: supports 15-bit port-addresses
+	return packet
+}
+
+func (t *ArtNetTransmitter) recordNode(packet []byte) {
+	if len(packet) < 207 {
+		return
+	}
+
+	node := ArtNetNode{
+		Address:   net.IP(packet[10:14]).String(),
+		ShortName: cString(packet[26:44]),
+		LongName:  cString(packet[44:108]),
+		LastSeen:  time.Now(),
+	}
+
+	// a node with more than four ports sends one reply per group of four, so merge them
+	netSwitch, subSwitch := uint16(packet[18]&0x7F), uint16(packet[19]&0x0F)
+	ports := min(int(packet[173]), 4)
+	for i := 0; i < ports; i++ {
+		// port types with the output bit set
+		if packet[174+i]&0x80 == 0 {
+			continue
+		}
+		node.PortAddresses = append(node.PortAddresses, netSwitch<<8|subSwitch<<4|uint16(packet[190+i]&0x0F))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	existing, known := t.nodes[node.Address]
+	if known && time.Since(existing.LastSeen) < ArtNodeTimeout {
+		for _, address := range existing.PortAddresses {
+			if !slices.Contains(node.PortAddresses, address) {
+				node.PortAddresses = append(node.PortAddresses, address)
+			}
+		}
+	} else {
+		log.Printf("Art-Net node %s (%s) found at %s", node.ShortName, node.LongName, node.Address)
+	}
+	sort.Slice(node.PortAddresses, func(i, j int) bool { return node.PortAddresses[i] < node.PortAddresses[j] })
+	t.nodes[node.Address] = node
+}
+
+func (t *ArtNetTransmitter) Close() error {
+	close(t.done)
+	// unblock the receive loop
+	t.conn.SetReadDeadline(time.Now())
+	t.wg.Wait()
+
+	return t.conn.Close()
+}
+
+// returns the local address we'd use to reach the given address
+func outboundAddress(addr *net.UDPAddr) net.IP {
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return net.IPv4zero
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+func localAddresses() map[string]bool {
+	addresses := make(map[string]bool)
+	interfaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return addresses
+	}
+	for _, addr := range interfaceAddrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			addresses[ipNet.IP.String()] = true
+		}
+	}
+	return addresses
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
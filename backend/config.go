@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -12,10 +13,35 @@ import (
 const MAX_FRAMES_PER_SECOND = 120
 
 type Config struct {
-	ControllerAddress     string
+	AllowInvalidLayout    bool
+	CID                   [16]byte
+	ControllerAddresses   []string
+	ControlProtocol       string
+	ControlUniverse       uint16
+	ControlAddress        int
 	HostAddress           string
 	HostPort              string
+	LayoutFile            string
 	LocalOnly             bool
+	MulticastInterface    string
+	MulticastTTL          int
+	OPCServerAddress      string
+	OutputChangesOnly     bool
+	OutputProtocol        string
+	OutputPriority        uint8
+	Record                bool
+	RecordingDir          string
+	ReplayFile            string
+	ReplayLoop            bool
+	ReplayStep            bool
+	ShutdownFade          time.Duration
+	SourceName            string
+	SyncUniverse          uint16
+	SACNMulticast         bool
+	SACNDiscovery         bool
+	SACNInput             bool
+	SACNInputAddress      string
+	SACNInputMerge        string
 	TargetFramesPerSecond int
 	TransitionDuration    time.Duration
 	TransitionEnabled     bool
@@ -61,11 +87,124 @@ func loadConfig() *Config {
 		log.Fatalf("invalid value for TRANSITION_ENABLED")
 	}
 
+	sacnMulticast, err := strconv.ParseBool(getOptionalParameter("SACN_MULTICAST", "false"))
+	if err != nil {
+		log.Fatalf("invalid value for SACN_MULTICAST")
+	}
+
+	multicastTTL, err := strconv.Atoi(getOptionalParameter("SACN_MULTICAST_TTL", strconv.Itoa(DefaultMulticastTTL)))
+	if err != nil || multicastTTL < 1 || multicastTTL > 255 {
+		log.Fatalf("invalid value for SACN_MULTICAST_TTL")
+	}
+
+	syncUniverse, err := strconv.ParseUint(getOptionalParameter("SACN_SYNC_UNIVERSE", "0"), 10, 16)
+	if err != nil || syncUniverse > 63999 {
+		log.Fatalf("invalid value for SACN_SYNC_UNIVERSE")
+	}
+
+	shutdownFadeMs, err := strconv.Atoi(getOptionalParameter("SHUTDOWN_FADE_MS", "0"))
+	if err != nil || shutdownFadeMs < 0 {
+		log.Fatalf("invalid value for SHUTDOWN_FADE_MS")
+	}
+
+	// every universe is sent to each of a comma separated list of controllers, unless the
+	// layout says otherwise
+	controllerAddresses := []string{}
+	for _, address := range strings.Split(getRequiredParameter("CONTROLLER_ADDRESS"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			controllerAddresses = append(controllerAddresses, address)
+		}
+	}
+
+	outputPriority, err := strconv.ParseUint(getOptionalParameter("OUTPUT_PRIORITY", strconv.Itoa(int(DefaultPriority))), 10, 8)
+	if err != nil || outputPriority > uint64(MaxPriority) {
+		log.Fatalf("invalid value for OUTPUT_PRIORITY")
+	}
+
+	sacnInput, err := strconv.ParseBool(getOptionalParameter("SACN_INPUT", "false"))
+	if err != nil {
+		log.Fatalf("invalid value for SACN_INPUT")
+	}
+
+	// console control is off unless a universe is given
+	controlUniverse, err := strconv.ParseUint(getOptionalParameter("CONTROL_UNIVERSE", "0"), 10, 16)
+	if err != nil {
+		log.Fatalf("invalid value for CONTROL_UNIVERSE")
+	}
+
+	controlAddress, err := strconv.Atoi(getOptionalParameter("CONTROL_ADDRESS", "1"))
+	if err != nil {
+		log.Fatalf("invalid value for CONTROL_ADDRESS")
+	}
+
+	// identifies this instance to sACN receivers, random on each start unless it's set
+	cid := newCID()
+	if value := getOptionalParameter("SACN_CID", ""); value != "" {
+		if cid, err = parseCID(value); err != nil {
+			log.Fatalf("invalid value for SACN_CID: %v", err)
+		}
+	}
+
+	sacnDiscovery, err := strconv.ParseBool(getOptionalParameter("SACN_DISCOVERY", "true"))
+	if err != nil {
+		log.Fatalf("invalid value for SACN_DISCOVERY")
+	}
+
+	record, err := strconv.ParseBool(getOptionalParameter("RECORD", "false"))
+	if err != nil {
+		log.Fatalf("invalid value for RECORD")
+	}
+
+	replayLoop, err := strconv.ParseBool(getOptionalParameter("REPLAY_LOOP", "false"))
+	if err != nil {
+		log.Fatalf("invalid value for REPLAY_LOOP")
+	}
+
+	replayStep, err := strconv.ParseBool(getOptionalParameter("REPLAY_STEP", "false"))
+	if err != nil {
+		log.Fatalf("invalid value for REPLAY_STEP")
+	}
+
+	outputChangesOnly, err := strconv.ParseBool(getOptionalParameter("OUTPUT_CHANGES_ONLY", "true"))
+	if err != nil {
+		log.Fatalf("invalid value for OUTPUT_CHANGES_ONLY")
+	}
+
+	allowInvalidLayout, err := strconv.ParseBool(getOptionalParameter("ALLOW_INVALID_LAYOUT", "false"))
+	if err != nil {
+		log.Fatalf("invalid value for ALLOW_INVALID_LAYOUT")
+	}
+
 	return &Config{
+		AllowInvalidLayout:    allowInvalidLayout,
+		CID:                   cid,
 		HostAddress:           getRequiredParameter("HOST_ADDRESS"),
 		HostPort:              getRequiredParameter("HOST_PORT"),
-		ControllerAddress:     getRequiredParameter("CONTROLLER_ADDRESS"),
+		LayoutFile:            getOptionalParameter("LAYOUT_FILE", "layout.json"),
+		ControllerAddresses:   controllerAddresses,
+		ControlProtocol:       strings.ToLower(getOptionalParameter("CONTROL_PROTOCOL", PROTOCOL_SACN)),
+		ControlUniverse:       uint16(controlUniverse),
+		ControlAddress:        controlAddress,
 		LocalOnly:             localOnly,
+		MulticastInterface:    getOptionalParameter("SACN_MULTICAST_INTERFACE", ""),
+		MulticastTTL:          multicastTTL,
+		OPCServerAddress:      getOptionalParameter("OPC_SERVER_ADDRESS", ""),
+		OutputChangesOnly:     outputChangesOnly,
+		OutputPriority:        uint8(outputPriority),
+		Record:                record,
+		RecordingDir:          getOptionalParameter("RECORDING_DIR", "recordings"),
+		ReplayFile:            getOptionalParameter("REPLAY_FILE", ""),
+		ReplayLoop:            replayLoop,
+		ReplayStep:            replayStep,
+		OutputProtocol:        strings.ToLower(getOptionalParameter("OUTPUT_PROTOCOL", PROTOCOL_SACN)),
+		SACNMulticast:         sacnMulticast,
+		SACNDiscovery:         sacnDiscovery,
+		SACNInput:             sacnInput,
+		SACNInputAddress:      getOptionalParameter("SACN_INPUT_ADDRESS", ""),
+		SACNInputMerge:        strings.ToLower(getOptionalParameter("SACN_INPUT_MERGE", MERGE_HTP)),
+		ShutdownFade:          time.Duration(shutdownFadeMs) * time.Millisecond,
+		SourceName:            getOptionalParameter("SOURCE_NAME", OUTPUT_SOURCE_NAME),
+		SyncUniverse:          uint16(syncUniverse),
 		TargetFramesPerSecond: targetFramesPerSecond,
 		TransitionDuration:    time.Duration(transitionDurationMs) * time.Millisecond,
 		TransitionEnabled:     transitionEnabled,
@@ -79,3 +218,11 @@ func getRequiredParameter(envParameter string) string {
 	}
 	return value
 }
+
+func getOptionalParameter(envParameter string, defaultValue string) string {
+	value, ok := os.LookupEnv(envParameter)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+)
+
+// DDP (distributed display protocol) carries a device's whole pixel buffer as byte offsets,
+// so a long strip doesn't have to be cut into 512 channel universes. a frame is split into
+// packets of at most DDPMaxData bytes, and the last one carries the push flag telling the
+// device to display everything it has received.
+
+const (
+	DDPPort          = 4048
+	DDPHeaderLength  = 10
+	DDPMaxData       = 1440 // 480 RGB pixels, keeps packets under a standard MTU
+	DDPFlagVersion1  = uint8(0x40)
+	DDPFlagPush      = uint8(0x01)
+	DDPTypeUndefined = uint8(0x00) // buffers can mix RGB and RGBW pixels, devices go by their own config
+	DDPIDDisplay     = uint8(0x01)
+)
+
+// DDPConfig sends segments to a DDP device as one buffer instead of through universes. the
+// segments are laid out one after another in the order they're listed.
+type DDPConfig struct {
+	Name     string   `json:"name"`
+	Address  string   `json:"address"`
+	Segments []string `json:"segments"`
+}
+
+// DDPSegmentInfo is where a segment's pixels sit in its device's buffer
+type DDPSegmentInfo struct {
+	Name   string `json:"name"`
+	Offset int    `json:"offset"` // in bytes
+	Length int    `json:"length"` // in bytes
+}
+
+type DDPDeviceInfo struct {
+	Name     string           `json:"name"`
+	Address  string           `json:"address"`
+	Segments []DDPSegmentInfo `json:"segments"`
+}
+
+type DDPTransmitter struct {
+	streams *deviceStreams
+}
+
+type DDPDevice struct {
+	deviceStream
+	sequence uint8
+	packet   []byte // reused for every packet sent
+}
+
+func NewDDPTransmitter() (*DDPTransmitter, error) {
+	streams, err := newDeviceStreams("ddp device")
+	if err != nil {
+		return nil, err
+	}
+	return &DDPTransmitter{streams: streams}, nil
+}
+
+func (t *DDPTransmitter) Activate(name string, address string) (*FrameSlot, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", address, DDPPort))
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", address, err)
+	}
+
+	return t.streams.add(&DDPDevice{
+		deviceStream: deviceStream{name: name, addr: udpAddr},
+		packet:       make([]byte, DDPHeaderLength+DDPMaxData),
+	})
+}
+
+func (device *DDPDevice) createPacket(offset int, data []byte, push bool) []byte {
+	packet := device.packet[:DDPHeaderLength+len(data)]
+
+	packet[0] = DDPFlagVersion1
+	if push {
+		packet[0] |= DDPFlagPush
+	}
+	packet[1] = device.sequence & 0x0F
+	packet[2] = DDPTypeUndefined
+	packet[3] = DDPIDDisplay
+	binary.BigEndian.PutUint32(packet[4:8], uint32(offset))
+	binary.BigEndian.PutUint16(packet[8:10], uint16(len(data)))
+	copy(packet[DDPHeaderLength:], data)
+
+	return packet
+}
+
+// sends the device's buffer, pushing on the last packet so the whole frame appears at once
+func (device *DDPDevice) sendFrame(conn *net.UDPConn) {
+	if len(device.data) == 0 {
+		return
+	}
+
+	for offset := 0; offset < len(device.data); offset += DDPMaxData {
+		end := min(offset+DDPMaxData, len(device.data))
+
+		// sequence numbers run from 1 to 15, 0 means they aren't used
+		device.sequence = device.sequence%15 + 1
+
+		packet := device.createPacket(offset, device.data[offset:end], end == len(device.data))
+		if _, err := conn.WriteToUDP(packet, device.addr); err != nil {
+			log.Printf("Error sending to ddp device %s: %v", device.name, err)
+			return
+		}
+	}
+}
+
+func (t *DDPTransmitter) Close() error {
+	return t.streams.Close()
+}
+
+// returns the names of every segment sent over DDP
+func ddpSegments(devices []DDPConfig) map[string]bool {
+	segments := make(map[string]bool)
+	for _, device := range devices {
+		for _, name := range device.Segments {
+			segments[name] = true
+		}
+	}
+	return segments
+}
+
+// checks the DDP devices against the segments in the map
+func (p *PixelMap) checkDDP() error {
+	devices := make(map[string]bool)
+	claimed := make(map[string]string)
+	for _, device := range p.ddp {
+		if device.Name == "" || device.Address == "" {
+			return fmt.Errorf("ddp devices need a name and an address")
+		}
+		if devices[device.Name] {
+			return fmt.Errorf("ddp device %s is defined more than once", device.Name)
+		}
+		devices[device.Name] = true
+
+		if len(device.Segments) == 0 {
+			return fmt.Errorf("ddp device %s has no segments", device.Name)
+		}
+		for _, name := range device.Segments {
+			if _, err := p.findSegment(name); err != nil {
+				return fmt.Errorf("ddp device %s: %w", device.Name, err)
+			}
+			if other, exists := claimed[name]; exists {
+				return fmt.Errorf("ddp device %s: segment %s is already sent to %s", device.Name, name, other)
+			}
+			claimed[name] = device.Name
+		}
+	}
+	return nil
+}
+
+// returns the pixels of a DDP device in the order they're laid out in its buffer
+func (p *PixelMap) ddpDevicePixels(device DDPConfig) []*Pixel {
+	pixels := []*Pixel{}
+	for _, name := range device.Segments {
+		index, err := p.findSegment(name)
+		if err != nil {
+			continue
+		}
+		pixels = append(pixels, segmentPixelsInWiringOrder(p, p.segments[index])...)
+	}
+	return pixels
+}
+
+// describes where each segment of a DDP device sits in its buffer
+func (p *PixelMap) ddpDeviceInfo(device DDPConfig) DDPDeviceInfo {
+	info := DDPDeviceInfo{Name: device.Name, Address: device.Address, Segments: []DDPSegmentInfo{}}
+	offset := 0
+	for _, name := range device.Segments {
+		index, err := p.findSegment(name)
+		if err != nil {
+			continue
+		}
+		length := 0
+		for _, pixel := range segmentPixelsInWiringOrder(p, p.segments[index]) {
+			length += int(pixel.pixelType)
+		}
+		info.Segments = append(info.Segments, DDPSegmentInfo{Name: name, Offset: offset, Length: length})
+		offset += length
+	}
+	return info
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DDP devices and WLED nodes are both sent whole buffers over UDP, and both drop out of
+// realtime mode when they stop hearing from us. deviceStreams runs what they have in common:
+// a goroutine per device sending each frame as it's published, and a keep-alive resending
+// the last frame to devices that have been idle.
+
+// streamDevice is a device deviceStreams sends to, which knows how to put a frame on the wire
+type streamDevice interface {
+	stream() *deviceStream
+	sendFrame(conn *net.UDPConn)
+}
+
+// deviceStream is the state every streamed device has, embedded in each kind of device
+type deviceStream struct {
+	name     string
+	addr     *net.UDPAddr
+	data     []byte
+	slot     *FrameSlot
+	lastSent time.Time
+}
+
+type deviceStreams struct {
+	kind    string // what the devices are called in errors, e.g. "ddp device"
+	conn    *net.UDPConn
+	devices map[string]streamDevice
+	mu      sync.Mutex
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newDeviceStreams(kind string) (*deviceStreams, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create UDP connection: %w", err)
+	}
+
+	s := &deviceStreams{
+		kind:    kind,
+		conn:    conn,
+		devices: make(map[string]streamDevice),
+		done:    make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.keepAliveLoop()
+
+	return s, nil
+}
+
+func (s *deviceStream) stream() *deviceStream {
+	return s
+}
+
+// copies a frame out of the device's slot
+func (s *deviceStream) setData(data []byte) {
+	s.data = append(s.data[:0], data...)
+}
+
+// starts sending to the device, returning the slot its frames are published to
+func (s *deviceStreams) add(device streamDevice) (*FrameSlot, error) {
+	stream := device.stream()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.devices[stream.name]; exists {
+		return nil, fmt.Errorf("%s %s already activated", s.kind, stream.name)
+	}
+
+	stream.slot = NewFrameSlot()
+	s.devices[stream.name] = device
+
+	s.wg.Add(1)
+	go s.handleDevice(device)
+
+	return stream.slot, nil
+}
+
+func (s *deviceStreams) handleDevice(device streamDevice) {
+	defer s.wg.Done()
+
+	stream := device.stream()
+	for {
+		select {
+		case <-stream.slot.Ready():
+			s.mu.Lock()
+			if stream.slot.Take(stream.setData) {
+				stream.lastSent = time.Now()
+				device.sendFrame(s.conn)
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// devices fall back to their own effects once they stop hearing from us, so idle frames are
+// resent
+func (s *deviceStreams) keepAliveLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(KeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			now := time.Now()
+			for _, device := range s.devices {
+				if now.Sub(device.stream().lastSent) >= KeepAliveInterval {
+					device.sendFrame(s.conn)
+				}
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *deviceStreams) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	return s.conn.Close()
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// a lighting console can run GoLEDz like any other fixture. one universe, sACN or Art-Net,
+// carries a small control profile starting at the fixture's DMX address:
+//
+//	1      pattern, 0 leaves it alone and 1-255 are split evenly over the patterns by name
+//	2      color mask, the same way over the masks
+//	3      brightness
+//	4-10   the current pattern's float parameters in the order they're declared, scaled
+//	       to their min and max
+//
+// a control is only applied when its channel changes, so the web UI keeps working while the
+// console's faders sit still.
+
+const (
+	CONTROL_PATTERN_CHANNEL    = 0
+	CONTROL_MASK_CHANNEL       = 1
+	CONTROL_BRIGHTNESS_CHANNEL = 2
+	CONTROL_PARAMETER_CHANNEL  = 3
+	CONTROL_PARAMETER_CHANNELS = 7
+	CONTROL_FOOTPRINT          = CONTROL_PARAMETER_CHANNEL + CONTROL_PARAMETER_CHANNELS
+)
+
+// DMXInput delivers the DMX data received for a universe
+type DMXInput interface {
+	Subscribe(universe uint16, handler func(data []byte)) error
+}
+
+type DMXControl struct {
+	server   *LEDServer
+	protocol string
+	universe uint16
+	address  int // the first channel of the profile, from 1
+	last     []byte
+	pattern  string // the pattern last selected from the console
+	replaced string // and the one it replaced, until the switch shows up in the controller
+	mu       sync.Mutex
+}
+
+// DMXControlInfo describes the profile so it can be patched on the console
+type DMXControlInfo struct {
+	Protocol string               `json:"protocol"`
+	Universe uint16               `json:"universe"`
+	Address  int                  `json:"address"`
+	Channels []DMXControlChannels `json:"channels"`
+}
+
+type DMXControlChannels struct {
+	Channel int               `json:"channel"`
+	Control string            `json:"control"`
+	Values  []DMXControlRange `json:"values,omitempty"`
+}
+
+type DMXControlRange struct {
+	From uint8  `json:"from"`
+	To   uint8  `json:"to"`
+	Name string `json:"name"`
+}
+
+// ListenForControl has the server take the control profile from the input
+func (s *LEDServer) ListenForControl(input DMXInput, protocol string, universe uint16, address int) error {
+	if address < 1 || address+CONTROL_FOOTPRINT-1 > UniverseChannels {
+		return fmt.Errorf("control address %d doesn't leave room for %d channels", address, CONTROL_FOOTPRINT)
+	}
+
+	control := &DMXControl{
+		server:   s,
+		protocol: protocol,
+		universe: universe,
+		address:  address,
+	}
+	if err := input.Subscribe(universe, control.handle); err != nil {
+		return fmt.Errorf("failed to listen for control on universe %d: %w", universe, err)
+	}
+	s.control = control
+
+	log.Printf("Listening for %s control on universe %d at address %d", protocol, universe, address)
+	return nil
+}
+
+func (c *DMXControl) handle(data []byte) {
+	frame := make([]byte, CONTROL_FOOTPRINT)
+	if c.address <= len(data) {
+		copy(frame, data[c.address-1:])
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.last
+	c.last = frame
+	changed := func(channel int) bool {
+		return previous == nil || previous[channel] != frame[channel]
+	}
+
+	s := c.server
+	selected := false
+	if changed(CONTROL_PATTERN_CHANNEL) {
+		if name, ok := selectByValue(sortedNames(s.patterns), frame[CONTROL_PATTERN_CHANNEL]); ok {
+			c.replaced, c.pattern = c.currentPattern(), name
+			c.updatePattern(name, frame, func(int) bool { return true })
+			selected = true
+		}
+	}
+
+	parametersChanged := false
+	for i := range CONTROL_PARAMETER_CHANNELS {
+		parametersChanged = parametersChanged || changed(CONTROL_PARAMETER_CHANNEL+i)
+	}
+	if parametersChanged && !selected {
+		c.updatePattern(c.currentPattern(), frame, changed)
+	}
+
+	if changed(CONTROL_MASK_CHANNEL) {
+		if name, ok := selectByValue(sortedNames(s.colorMasks), frame[CONTROL_MASK_CHANNEL]); ok {
+			if err := s.controller.SetColorMask(s.colorMasks[name]); err != nil {
+				log.Printf("Error setting color mask from console: %v", err)
+			}
+		}
+	}
+
+	if changed(CONTROL_BRIGHTNESS_CHANNEL) {
+		c.setBrightness(frame[CONTROL_BRIGHTNESS_CHANNEL])
+	}
+}
+
+// the pattern parameters apply to, whichever was last selected from the console or the UI
+func (c *DMXControl) currentPattern() string {
+	current := ""
+	if pattern := c.server.controller.currentPattern; pattern != nil {
+		current = pattern.GetName()
+	}
+	if c.pattern != "" && (current == c.pattern || current == c.replaced) {
+		return c.pattern
+	}
+	return current
+}
+
+// switches to the pattern, or updates it if it's current, setting the parameters whose
+// channels changed
+func (c *DMXControl) updatePattern(name string, frame []byte, changed func(channel int) bool) {
+	pattern, exists := c.server.patterns[name]
+	if !exists {
+		return
+	}
+
+	request := pattern.GetPatternUpdateRequest()
+	for i, field := range floatParameters(request) {
+		if i >= CONTROL_PARAMETER_CHANNELS {
+			break
+		}
+		channel := CONTROL_PARAMETER_CHANNEL + i
+		if changed(channel) {
+			field.parameter.Value = scaleToParameter(frame[channel], field.parameter)
+		}
+	}
+
+	if err := c.server.controller.UpdatePattern(name, request); err != nil {
+		log.Printf("Error updating pattern %s from console: %v", name, err)
+	}
+}
+
+func (c *DMXControl) setBrightness(value uint8) {
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	option, err := s.options.GetOption("brightness")
+	if err != nil {
+		return
+	}
+	brightness := option.(*FloatOption)
+	level := brightness.Min + float64(value)/255*(brightness.Max-brightness.Min)
+	if err := s.options.SetOption("brightness", level); err != nil {
+		log.Printf("Error setting brightness from console: %v", err)
+		return
+	}
+	s.controller.UpdateOptions(s.options.share())
+}
+
+// a float parameter of a pattern's update request, pointing into the request
+type floatParameterField struct {
+	name      string
+	parameter *FloatParameter
+}
+
+// returns the float parameters of a pattern's update request in declaration order
+func floatParameters(request PatternUpdateRequest) []floatParameterField {
+	value := reflect.ValueOf(request)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	params := value.Elem().FieldByName("Parameters")
+	if !params.IsValid() || params.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := []floatParameterField{}
+	for i := 0; i < params.NumField(); i++ {
+		field := params.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if parameter, ok := params.Field(i).Addr().Interface().(*FloatParameter); ok {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" {
+				name = field.Name
+			}
+			fields = append(fields, floatParameterField{name: name, parameter: parameter})
+		}
+	}
+	return fields
+}
+
+func scaleToParameter(value uint8, parameter *FloatParameter) float64 {
+	minimum := 0.0
+	if parameter.Min != nil {
+		minimum = *parameter.Min
+	}
+	return minimum + float64(value)/255*(parameter.Max-minimum)
+}
+
+func sortedNames[T any](items map[string]T) []string {
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// picks a name by DMX value, 0 picking nothing and 1-255 split evenly over the names
+func selectByValue(names []string, value uint8) (string, bool) {
+	if value == 0 || len(names) == 0 {
+		return "", false
+	}
+	return names[(int(value)-1)*len(names)/255], true
+}
+
+// returns the DMX values that select each name
+func valueRanges(names []string) []DMXControlRange {
+	ranges := []DMXControlRange{}
+	for value := 1; value <= 255; value++ {
+		name, _ := selectByValue(names, uint8(value))
+		if last := len(ranges) - 1; last >= 0 && ranges[last].Name == name {
+			ranges[last].To = uint8(value)
+			continue
+		}
+		ranges = append(ranges, DMXControlRange{From: uint8(value), To: uint8(value), Name: name})
+	}
+	return ranges
+}
+
+// Info describes the profile, with the parameter channels of the current pattern
+func (c *DMXControl) Info() DMXControlInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.server
+	info := DMXControlInfo{
+		Protocol: c.protocol,
+		Universe: c.universe,
+		Address:  c.address,
+		Channels: []DMXControlChannels{
+			{Channel: c.address + CONTROL_PATTERN_CHANNEL, Control: "pattern", Values: valueRanges(sortedNames(s.patterns))},
+			{Channel: c.address + CONTROL_MASK_CHANNEL, Control: "colorMask", Values: valueRanges(sortedNames(s.colorMasks))},
+			{Channel: c.address + CONTROL_BRIGHTNESS_CHANNEL, Control: "brightness"},
+		},
+	}
+
+	if pattern, exists := s.patterns[c.currentPattern()]; exists {
+		for i, field := range floatParameters(pattern.GetPatternUpdateRequest()) {
+			if i >= CONTROL_PARAMETER_CHANNELS {
+				break
+			}
+			info.Channels = append(info.Channels, DMXControlChannels{
+				Channel: c.address + CONTROL_PARAMETER_CHANNEL + i,
+				Control: pattern.GetName() + "." + field.name,
+			})
+		}
+	}
+	return info
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// frames are handed to outputs latest wins. the controller publishes each frame into the
+// output's slot without waiting, and an output that hasn't taken the previous frame yet
+// simply never sees it, so a slow socket drops frames rather than stalling rendering or
+// playing a backlog late.
+
+// FrameSlot holds the latest frame published to an output until the output takes it
+type FrameSlot struct {
+	frame     []byte // a copy, so publishers can reuse their buffers straight away
+	pending   bool
+	published time.Time
+	interval  time.Duration // frames taken later than this after publishing are late
+	ready     chan struct{}
+	metrics   FrameMetrics
+	mu        sync.Mutex
+}
+
+// FrameMetrics counts what became of the frames published to an output
+type FrameMetrics struct {
+	Published uint64 `json:"published"`
+	Dropped   uint64 `json:"dropped"`   // replaced by a newer frame before the output took them
+	Late      uint64 `json:"late"`      // taken more than a frame interval after being published
+	Unchanged uint64 `json:"unchanged"` // not published because they matched the frame before
+}
+
+// OutputMetrics is the frame metrics of every universe and device
+type OutputMetrics struct {
+	Universes []UniverseMetrics `json:"universes"`
+	Devices   []DeviceMetrics   `json:"devices,omitempty"`
+}
+
+type UniverseMetrics struct {
+	Universe uint16 `json:"universe"`
+	FrameMetrics
+}
+
+type DeviceMetrics struct {
+	Name string `json:"name"`
+	FrameMetrics
+}
+
+func NewFrameSlot() *FrameSlot {
+	return &FrameSlot{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// Publish copies the frame into the slot, replacing any frame the output hasn't taken yet.
+// it never blocks.
+func (s *FrameSlot) Publish(data []byte) {
+	s.mu.Lock()
+	s.publish(data)
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// PublishChanged publishes the frame only if it differs from the last one published,
+// reporting whether it did. outputs resend their last frame on their keep-alive cadence, so
+// unchanged frames can be left out.
+func (s *FrameSlot) PublishChanged(data []byte) bool {
+	s.mu.Lock()
+	if s.metrics.Published > 0 && bytes.Equal(s.frame, data) {
+		s.metrics.Unchanged++
+		s.mu.Unlock()
+		return false
+	}
+	s.publish(data)
+	s.mu.Unlock()
+
+	s.notify()
+	return true
+}
+
+func (s *FrameSlot) publish(data []byte) {
+	if s.pending {
+		s.metrics.Dropped++
+	}
+	s.frame = append(s.frame[:0], data...)
+	s.pending = true
+	s.published = time.Now()
+	s.metrics.Published++
+}
+
+func (s *FrameSlot) notify() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+		// the output has already been told there's a frame waiting
+	}
+}
+
+// Ready receives whenever a frame may be waiting
+func (s *FrameSlot) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Take calls fn with the waiting frame, if there is one, which it has to copy before
+// returning. it reports whether there was a frame.
+func (s *FrameSlot) Take(fn func(data []byte)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.pending {
+		return false
+	}
+	s.pending = false
+	if s.interval > 0 && time.Since(s.published) > s.interval {
+		s.metrics.Late++
+	}
+	fn(s.frame)
+	return true
+}
+
+// SetInterval sets how long a frame can wait in the slot before it counts as late, usually
+// the time between frames. late frames aren't counted until it's set.
+func (s *FrameSlot) SetInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.interval = interval
+}
+
+func (s *FrameSlot) Metrics() FrameMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.metrics
+}
@@ -61,7 +61,9 @@ func build2ChannelsOfPixels() *[]Pixel {
 	return &pixels
 }
 
-func buildMammothSegment(universe uint16, startingChannelPosition uint16, xStart int16, yStart int16, rotationDegrees int16, sections []Section, pixelType PixelType, colorOrder ColorOrder) *[]Pixel {
+// the mammoth segments mix two kinds of pixel, whose size and weight come from the segment's
+// "big" and "small" pixel kinds
+func buildMammothSegment(universe uint16, startingChannelPosition uint16, xStart int16, yStart int16, rotationDegrees int16, big PixelKind, small PixelKind, sections []Section, pixelType PixelType, colorOrder ColorOrder) *[]Pixel {
 	pixels := []Pixel{}
 	xPos := xStart
 	yPos := yStart
@@ -78,6 +80,8 @@ func buildMammothSegment(universe uint16, startingChannelPosition uint16, xStart
 			universe:        universe,
 			channelPosition: channelPosition,
 			sections:        sections,
+			size:            big.Size,
+			weight:          big.Weight,
 			pixelType:       pixelType,
 			color:           Color{R: 0, G: 0, B: 0, W: 0},
 			colorOrder:      colorOrder,
@@ -102,6 +106,8 @@ func buildMammothSegment(universe uint16, startingChannelPosition uint16, xStart
 			universe:        universe,
 			channelPosition: channelPosition,
 			sections:        sections,
+			size:            big.Size,
+			weight:          big.Weight,
 			pixelType:       pixelType,
 			color:           Color{R: 0, G: 0, B: 0, W: 0},
 			colorOrder:      colorOrder,
@@ -126,6 +132,8 @@ func buildMammothSegment(universe uint16, startingChannelPosition uint16, xStart
 			universe:        universe,
 			channelPosition: channelPosition,
 			sections:        sections,
+			size:            small.Size,
+			weight:          small.Weight,
 			pixelType:       pixelType,
 			color:           Color{R: 0, G: 0, B: 0, W: 0},
 			colorOrder:      colorOrder,
@@ -154,6 +162,34 @@ func buildTuskSegment(universe uint16, startingChannelNumber uint16, xStart int1
 			universe:        universe,
 			channelPosition: channelPosition,
 			sections:        sections,
+			size:            float64(pixelsSpacing),
+			pixelType:       pixelType,
+			color:           Color{R: 0, G: 0, B: 0, W: 0},
+			colorOrder:      colorOrder,
+		})
+		xTranslated, yTranslated := rotate(pixelsSpacing, 0, rotationDegrees)
+		xPos += xTranslated
+		yPos += yTranslated
+		channelPosition += 1
+	}
+	return &pixels
+}
+
+// builds a straight run of evenly spaced pixels, for props that aren't one of our custom shapes
+func buildLineSegment(universe uint16, startingChannelNumber uint16, xStart int16, yStart int16, rotationDegrees int16, totalPixels int, pixelsSpacing int16, sections []Section, pixelType PixelType, colorOrder ColorOrder) *[]Pixel {
+	pixels := []Pixel{}
+	xPos := xStart
+	yPos := yStart
+	channelPosition := startingChannelNumber
+
+	for i := 0; i < totalPixels; i++ {
+		pixels = append(pixels, Pixel{
+			x:               xPos,
+			y:               yPos,
+			universe:        universe,
+			channelPosition: channelPosition,
+			sections:        sections,
+			size:            math.Abs(float64(pixelsSpacing)),
 			pixelType:       pixelType,
 			color:           Color{R: 0, G: 0, B: 0, W: 0},
 			colorOrder:      colorOrder,
@@ -199,5 +235,6 @@ func intPointer(value int) *int {
 func distanceBetweenPoints(p1 Point, p2 Point) float64 {
 	dx := float64(p1.X - p2.X)
 	dy := float64(p1.Y - p2.Y)
-	return math.Sqrt(dx*dx + dy*dy)
+	dz := float64(p1.Z - p2.Z)
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// patterns used to assume the installation filled an 800x800 square centered on (400, 400).
+// the geometry is measured from the pixels themselves instead, once when the map is built
+// and again whenever the layout changes, so radial patterns spin around the real middle of
+// whatever layout is loaded.
+
+// Bounds is the box enclosing every pixel in the map
+type Bounds struct {
+	Min Point `json:"min"`
+	Max Point `json:"max"`
+}
+
+func (b Bounds) Width() float64 {
+	return float64(b.Max.X) - float64(b.Min.X)
+}
+
+func (b Bounds) Height() float64 {
+	return float64(b.Max.Y) - float64(b.Min.Y)
+}
+
+func (b Bounds) Depth() float64 {
+	return float64(b.Max.Z) - float64(b.Min.Z)
+}
+
+// returns the corners of the box, including the back face for layouts with depth
+func (b Bounds) Corners() []Point {
+	corners := []Point{}
+	for _, z := range []int16{b.Min.Z, b.Max.Z} {
+		corners = append(corners,
+			Point{b.Min.X, b.Min.Y, z},
+			Point{b.Max.X, b.Min.Y, z},
+			Point{b.Min.X, b.Max.Y, z},
+			Point{b.Max.X, b.Max.Y, z},
+		)
+		if b.Min.Z == b.Max.Z {
+			break
+		}
+	}
+	return corners
+}
+
+type Geometry struct {
+	Bounds   Bounds `json:"bounds"`
+	Centroid Point  `json:"centroid"`
+	Origin   Point  `json:"origin"`
+
+	// with a fixed origin, the origin sits at originX/originY percent of the bounds rather
+	// than following the centroid
+	fixedOrigin bool
+	originX     float64
+	originY     float64
+}
+
+// measures the bounding box and centroid of the map and stores every pixel's position
+// normalised to 0..1 within the bounds
+func (p *PixelMap) computeGeometry() {
+	pixels := *p.pixels
+	geometry := Geometry{
+		fixedOrigin: p.geometry.fixedOrigin,
+		originX:     p.geometry.originX,
+		originY:     p.geometry.originY,
+	}
+
+	if len(pixels) > 0 {
+		bounds := Bounds{Min: Point{pixels[0].x, pixels[0].y, pixels[0].z}, Max: Point{pixels[0].x, pixels[0].y, pixels[0].z}}
+		var sumX, sumY, sumZ float64
+		for _, pixel := range pixels {
+			bounds.Min = Point{min(bounds.Min.X, pixel.x), min(bounds.Min.Y, pixel.y), min(bounds.Min.Z, pixel.z)}
+			bounds.Max = Point{max(bounds.Max.X, pixel.x), max(bounds.Max.Y, pixel.y), max(bounds.Max.Z, pixel.z)}
+			sumX += float64(pixel.x)
+			sumY += float64(pixel.y)
+			sumZ += float64(pixel.z)
+		}
+		count := float64(len(pixels))
+		geometry.Bounds = bounds
+		geometry.Centroid = Point{
+			X: int16(math.Round(sumX / count)),
+			Y: int16(math.Round(sumY / count)),
+			Z: int16(math.Round(sumZ / count)),
+		}
+	}
+
+	p.geometry = geometry
+	p.updateOrigin()
+
+	for i := range pixels {
+		pixels[i].nx, pixels[i].ny, pixels[i].nz = p.normalize(Point{pixels[i].x, pixels[i].y, pixels[i].z})
+	}
+	p.computeCompensation()
+}
+
+// sampling a pixel's footprint costs masks seven lookups instead of one, so only pixels well
+// over the layout's typical size are sampled. the typical size is the lower quartile of pixel
+// sizes, so the finer pixels set it even where big ones outnumber them. zone maps copy the
+// flag with their pixels rather than working it out from a part of the layout.
+func (p *PixelMap) computeSampling() {
+	pixels := *p.pixels
+	sizes := make([]float64, 0, len(pixels))
+	for _, pixel := range pixels {
+		if pixel.size > 0 {
+			sizes = append(sizes, pixel.size)
+		}
+	}
+
+	threshold := MIN_SAMPLED_PIXEL_SIZE
+	if len(sizes) > 0 {
+		sort.Float64s(sizes)
+		threshold = max(threshold, sizes[len(sizes)/4]*SAMPLED_PIXEL_SIZE_FACTOR)
+	}
+	for i := range pixels {
+		pixels[i].sampled = pixels[i].size >= threshold
+	}
+}
+
+// heavier pixels are dimmed to match the lightest pixel in the map, since we can't make the
+// light ones any brighter than full
+func (p *PixelMap) computeCompensation() {
+	pixels := *p.pixels
+	lightest := 0.0
+	for _, pixel := range pixels {
+		if weight := pixel.effectiveWeight(); lightest == 0 || weight < lightest {
+			lightest = weight
+		}
+	}
+	for i := range pixels {
+		pixels[i].compensation = lightest / pixels[i].effectiveWeight()
+	}
+}
+
+func (p *Pixel) effectiveWeight() float64 {
+	if p.weight <= 0 {
+		return 1
+	}
+	return p.weight
+}
+
+// moves the origin radial patterns spin around. with atCentroid set the x and y percentages
+// are ignored and the origin follows the centroid.
+func (p *PixelMap) setOrigin(atCentroid bool, xPercent float64, yPercent float64) {
+	p.geometry.fixedOrigin = !atCentroid
+	p.geometry.originX = xPercent
+	p.geometry.originY = yPercent
+	p.updateOrigin()
+}
+
+func (p *PixelMap) updateOrigin() {
+	geometry := &p.geometry
+	if !geometry.fixedOrigin {
+		geometry.Origin = geometry.Centroid
+		return
+	}
+
+	bounds := geometry.Bounds
+	geometry.Origin = Point{
+		X: bounds.Min.X + int16(math.Round(bounds.Width()*geometry.originX/100)),
+		Y: bounds.Min.Y + int16(math.Round(bounds.Height()*geometry.originY/100)),
+		Z: geometry.Centroid.Z,
+	}
+}
+
+// returns the point patterns should treat as the middle of the layout
+func (p *PixelMap) origin() Point {
+	return p.geometry.Origin
+}
+
+func (p *PixelMap) bounds() Bounds {
+	return p.geometry.Bounds
+}
+
+// returns the distance from the origin to the farthest corner of the bounds, which is the
+// largest radius a radial pattern needs to cover
+func (p *PixelMap) maxRadius() float64 {
+	radius := 1.0
+	for _, corner := range p.geometry.Bounds.Corners() {
+		radius = max(radius, distanceBetweenPoints(p.geometry.Origin, corner))
+	}
+	return radius
+}
+
+// maps a point into 0..1 on each axis of the bounds. flat axes map to 0.5.
+func (p *PixelMap) normalize(point Point) (float64, float64, float64) {
+	bounds := p.geometry.Bounds
+	return normalizeAxis(point.X, bounds.Min.X, bounds.Width()),
+		normalizeAxis(point.Y, bounds.Min.Y, bounds.Height()),
+		normalizeAxis(point.Z, bounds.Min.Z, bounds.Depth())
+}
+
+func normalizeAxis(value int16, minimum int16, size float64) float64 {
+	if size == 0 {
+		return 0.5
+	}
+	return (float64(value) - float64(minimum)) / size
+}
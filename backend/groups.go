@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+)
+
+// sections used to be a flat list assigned per segment. they can now be nested, so a pixel in
+// a section also belongs to every section above it (limbs > leftFrontLeg > leftFrontLeg1), and
+// a section can pick up pixels itself: whole segments, a region of the layout, or ranges of
+// pixel indexes. a section that does this is called a group; groups can also be created while
+// running, and are used by color correction and zones like any other section.
+
+var (
+	ErrGroupExists  = NewError("group already exists")
+	ErrInvalidGroup = NewError("invalid group")
+)
+
+type RegionPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type CircleRegion struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Radius float64 `json:"radius"`
+}
+
+// PixelRange is an inclusive range of indexes into the pixel map, as reported by GET /layout
+type PixelRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// GroupInfo describes a section for the API
+type GroupInfo struct {
+	Name     string   `json:"name"`
+	Label    string   `json:"label"`
+	Parent   string   `json:"parent,omitempty"`
+	Children []string `json:"children"`
+	Pixels   int      `json:"pixels"`
+}
+
+// reports whether the section selects pixels itself, rather than only through segments
+// listing it or through its children
+func (c SectionConfig) selectsPixels() bool {
+	return len(c.Segments) > 0 || len(c.Polygon) > 0 || c.Circle != nil || len(c.Pixels) > 0
+}
+
+// reports whether the pixel at the given index is selected by the section
+func (c SectionConfig) selects(pixelMap *PixelMap, index int) bool {
+	pixel := (*pixelMap.pixels)[index]
+	x, y := float64(pixel.x), float64(pixel.y)
+
+	if len(c.Segments) > 0 && slices.Contains(c.Segments, pixelMap.segmentNameAt(index)) {
+		return true
+	}
+	if len(c.Polygon) >= 3 && pointInPolygon(x, y, c.Polygon) {
+		return true
+	}
+	if c.Circle != nil {
+		dx, dy := x-c.Circle.X, y-c.Circle.Y
+		if dx*dx+dy*dy <= c.Circle.Radius*c.Circle.Radius {
+			return true
+		}
+	}
+	for _, r := range c.Pixels {
+		if index >= r.Start && index <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// even-odd ray casting test
+func pointInPolygon(x float64, y float64, polygon []RegionPoint) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// checks a section's parent and selectors against the sections and segments already known
+func (p *PixelMap) checkGroup(config SectionConfig) error {
+	if config.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidGroup)
+	}
+	if config.Parent != "" {
+		if _, exists := p.sections[config.Parent]; !exists {
+			return fmt.Errorf("%w: section %s has unknown parent %q", ErrInvalidGroup, config.Name, config.Parent)
+		}
+	}
+	for _, name := range config.Segments {
+		if _, err := p.findSegment(name); err != nil {
+			return fmt.Errorf("%w: section %s: %v", ErrInvalidGroup, config.Name, err)
+		}
+	}
+	if len(config.Polygon) > 0 && len(config.Polygon) < 3 {
+		return fmt.Errorf("%w: section %s: polygon needs at least 3 points", ErrInvalidGroup, config.Name)
+	}
+	if config.Circle != nil && config.Circle.Radius <= 0 {
+		return fmt.Errorf("%w: section %s: circle needs a positive radius", ErrInvalidGroup, config.Name)
+	}
+	for _, r := range config.Pixels {
+		if r.Start < 0 || r.End < r.Start {
+			return fmt.Errorf("%w: section %s: invalid pixel range %d-%d", ErrInvalidGroup, config.Name, r.Start, r.End)
+		}
+	}
+	return nil
+}
+
+// makes sure every parent exists and no section is its own ancestor
+func checkSectionHierarchy(sections map[string]Section) error {
+	for name := range sections {
+		visited := map[string]bool{}
+		for current := name; current != ""; current = sections[current].parent {
+			if visited[current] {
+				return fmt.Errorf("section %s is nested inside itself", name)
+			}
+			visited[current] = true
+			if _, exists := sections[current]; !exists {
+				return fmt.Errorf("section %s has unknown parent %q", name, current)
+			}
+		}
+	}
+	return nil
+}
+
+// evaluates every group's selectors for the pixels in [from, to), then adds each pixel to
+// the ancestors of the sections it belongs to
+func (p *PixelMap) applyGroups(from int, to int) {
+	pixels := *p.pixels
+	for i := from; i < to; i++ {
+		for _, group := range p.groups {
+			if group.selectsPixels() && group.selects(p, i) {
+				p.addPixelSection(i, p.sections[group.Name])
+			}
+		}
+
+		for j := 0; j < len(pixels[i].sections); j++ {
+			if parent := pixels[i].sections[j].parent; parent != "" {
+				p.addPixelSection(i, p.sections[parent])
+			}
+		}
+	}
+}
+
+func (p *PixelMap) addPixelSection(index int, section Section) {
+	pixel := &(*p.pixels)[index]
+	if pixel.inSection(section.name) {
+		return
+	}
+	// pixels of a segment share one sections slice, so never append into it in place
+	pixel.sections = append(slices.Clip(pixel.sections), section)
+}
+
+// describes every section with its children and pixel count
+func (p *PixelMap) groupInfo() []GroupInfo {
+	counts := make(map[string]int)
+	for _, pixel := range *p.pixels {
+		for _, section := range pixel.sections {
+			counts[section.name]++
+		}
+	}
+
+	children := make(map[string][]string)
+	for _, section := range p.sections {
+		if section.parent != "" {
+			children[section.parent] = append(children[section.parent], section.name)
+		}
+	}
+
+	groups := make([]GroupInfo, 0, len(p.sections))
+	for _, section := range p.sections {
+		sort.Strings(children[section.name])
+		groups = append(groups, GroupInfo{
+			Name:     section.name,
+			Label:    section.label,
+			Parent:   section.parent,
+			Children: append([]string{}, children[section.name]...),
+			Pixels:   counts[section.name],
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// Groups describes every section and group in the layout
+func (pc *PixelController) Groups() []GroupInfo {
+	pc.layoutMutex.RLock()
+	defer pc.layoutMutex.RUnlock()
+
+	return pc.pixelMap.groupInfo()
+}
+
+// AddGroup creates a section at runtime from a parent and selectors. the group can then be
+// color corrected and given a zone like any section from the layout file.
+func (pc *PixelController) AddGroup(config SectionConfig) (GroupInfo, error) {
+	pc.layoutMutex.Lock()
+	defer pc.layoutMutex.Unlock()
+
+	pixelMap := pc.pixelMap
+	if _, exists := pixelMap.sections[config.Name]; exists {
+		return GroupInfo{}, ErrGroupExists
+	}
+	if err := pixelMap.checkGroup(config); err != nil {
+		return GroupInfo{}, err
+	}
+	if !config.selectsPixels() {
+		return GroupInfo{}, fmt.Errorf("%w: group %s does not select any pixels", ErrInvalidGroup, config.Name)
+	}
+
+	label := config.Label
+	if label == "" {
+		label = config.Name
+	}
+	section := Section{name: config.Name, label: label, parent: config.Parent}
+	pixelMap.sections[section.name] = section
+	pixelMap.groups = append(pixelMap.groups, config)
+	pixelMap.applyGroups(0, len(*pixelMap.pixels))
+	pc.syncZoneLayouts()
+
+	pc.options.AddColorCorrectionSection(section)
+
+	info := GroupInfo{Name: section.name, Label: section.label, Parent: section.parent, Children: []string{}}
+	for _, pixel := range *pixelMap.pixels {
+		if pixel.inSection(section.name) {
+			info.Pixels++
+		}
+	}
+	log.Printf("Group %s created with %d pixels", info.Name, info.Pixels)
+	return info, nil
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"sync"
@@ -21,10 +23,21 @@ type LEDServer struct {
 	defaultTransition TransitionConfig
 	colorMasks        map[string]ColorMaskPattern
 	options           Options
+	outputs           *OutputHandler
+	shutdown          *Shutdown
+	input             *SACNReceiver
+	control           *DMXControl
+	recordingDir      string
 }
 
 type ServerConfig struct {
-	Options Options
+	Options  Options
+	Outputs  *OutputHandler
+	Shutdown *Shutdown
+	Input    *SACNReceiver
+
+	// where recordings started over http are written
+	RecordingDir string
 }
 
 type PatternsResponse struct {
@@ -51,12 +64,16 @@ func NewLEDServer(controller *PixelController, pixelMap *PixelMap, patterns map[
 	}
 
 	server := &LEDServer{
-		controller:  controller,
-		pixelMap:    pixelMap,
-		patterns:    patterns,
-		colorMasks:  registerColorMasks(),
-		subscribers: make([]chan *PixelMap, 0),
-		options:     config.Options,
+		controller:   controller,
+		pixelMap:     pixelMap,
+		patterns:     patterns,
+		colorMasks:   registerColorMasks(pixelMap),
+		subscribers:  make([]chan *PixelMap, 0),
+		options:      config.Options,
+		outputs:      config.Outputs,
+		shutdown:     config.Shutdown,
+		input:        config.Input,
+		recordingDir: config.RecordingDir,
 	}
 
 	if pattern, ok := patterns["spiral"]; ok {
@@ -82,9 +99,29 @@ func (s *LEDServer) SetupRoutes() *http.ServeMux {
 	mux.HandleFunc("GET /patterns", s.handleGetPatterns)
 	mux.HandleFunc("PUT /patterns/{pattern}", s.handleUpdatePattern)
 
+	// layout
+	mux.HandleFunc("GET /layout", s.handleGetLayout)
+	mux.HandleFunc("GET /layout/validate", s.handleValidateLayout)
+	mux.HandleFunc("PUT /layout/segments/{segment}", s.handleUpdateSegment)
+
 	// health check
 	mux.HandleFunc("GET /health", s.handleHealthCheck)
 
+	// where each universe is sent
+	mux.HandleFunc("GET /outputs", s.handleGetOutputs)
+	mux.HandleFunc("GET /outputs/metrics", s.handleGetOutputMetrics)
+
+	// sACN sources merged into the output
+	mux.HandleFunc("GET /inputs", s.handleGetInputs)
+
+	// recording universe output for replay
+	mux.HandleFunc("GET /recording", s.handleGetRecording)
+	mux.HandleFunc("POST /recording", s.handleStartRecording)
+	mux.HandleFunc("DELETE /recording", s.handleStopRecording)
+
+	// the console control profile
+	mux.HandleFunc("GET /control", s.handleGetControl)
+
 	// transition config
 	mux.HandleFunc("PUT /transition", s.handleUpdateTransition)
 
@@ -92,12 +129,25 @@ func (s *LEDServer) SetupRoutes() *http.ServeMux {
 	mux.HandleFunc("PUT /colorMasks/{mask}", s.handleSetColorMask)
 	mux.HandleFunc("DELETE /colorMasks", s.handleDisableColorMask)
 
+	// sections and groups
+	mux.HandleFunc("GET /groups", s.handleGetGroups)
+	mux.HandleFunc("POST /groups", s.handleCreateGroup)
+
+	// per-section zones
+	mux.HandleFunc("GET /zones", s.handleGetZones)
+	mux.HandleFunc("PUT /zones/{section}/patterns/{pattern}", s.handleUpdateZonePattern)
+	mux.HandleFunc("PUT /zones/{section}/colorMasks/{mask}", s.handleSetZoneColorMask)
+	mux.HandleFunc("DELETE /zones/{section}", s.handleRemoveZone)
+
 	// options endpoints
 	mux.HandleFunc("GET /options", s.handleGetOptions)
 	mux.HandleFunc("PUT /options/{option}", s.handleUpdateOption)
 	mux.HandleFunc("POST /options/reset", s.handleResetOptions)
 	mux.HandleFunc("POST /options/resetColorCorrection", s.handleResetColorCorrection)
 
+	// admin
+	mux.HandleFunc("POST /shutdown", s.handleShutdown)
+
 	return mux
 }
 
@@ -246,11 +296,34 @@ func (s *LEDServer) handleUpdatePattern(w http.ResponseWriter, r *http.Request)
 	pathParts := r.URL.Path
 	patternName := pathParts[len("/patterns/"):]
 
+	// Check if we have a pattern update request
+	pattern, exists := s.controller.patterns[patternName]
+	if !exists {
+		http.Error(w, fmt.Sprintf("Pattern %s not found", patternName), http.StatusNotFound)
+		return
+	}
+
+	updateRequest, err := decodePatternUpdateRequest(r, pattern)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Update the pattern
+	if err := s.controller.UpdatePattern(patternName, updateRequest); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// decodes a pattern's parameters from the request body into its update request
+func decodePatternUpdateRequest(r *http.Request, pattern Pattern) (PatternUpdateRequest, error) {
 	// Parse request body
 	var requestData map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return nil, err
 	}
 
 	// Process the request to ensure W is 0 in all color values
@@ -266,13 +339,6 @@ func (s *LEDServer) handleUpdatePattern(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	// Check if we have a pattern update request
-	pattern, exists := s.controller.patterns[patternName]
-	if !exists {
-		http.Error(w, fmt.Sprintf("Pattern %s not found", patternName), http.StatusNotFound)
-		return
-	}
-
 	// Create a new pattern update request
 	updateRequest := pattern.GetPatternUpdateRequest()
 
@@ -283,34 +349,167 @@ func (s *LEDServer) handleUpdatePattern(w http.ResponseWriter, r *http.Request)
 	// Convert the processed request data back to JSON
 	jsonData, err := json.Marshal(requestData)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	// Unmarshal into the pattern-specific update request
 	if err := json.Unmarshal(jsonData, updateRequest); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return nil, err
 	}
 
 	// Log the changes
 	newParamsJSON, _ := json.Marshal(updateRequest.GetParameters())
 	log.Printf("Pattern updated: %s\nPrevious parameters: %s\nNew parameters: %s",
-		patternName, previousParamsJSON, newParamsJSON)
+		pattern.GetName(), previousParamsJSON, newParamsJSON)
 
-	// Update the pattern
-	if err := s.controller.UpdatePattern(patternName, updateRequest); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	return updateRequest, nil
+}
+
+func (s *LEDServer) handleGetLayout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.controller.LayoutSnapshot())
+}
+
+func (s *LEDServer) handleUpdateSegment(w http.ResponseWriter, r *http.Request) {
+	segmentName := r.PathValue("segment")
+
+	var request SegmentUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	report, err := s.controller.UpdateSegment(segmentName, request)
+	if err == ErrSegmentNotFound {
+		http.Error(w, fmt.Sprintf("Segment %s not found", segmentName), http.StatusNotFound)
+		return
+	}
+	if err != nil && err != ErrInvalidLayout {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err == ErrInvalidLayout {
+		// send the report back so the caller can see what the change would break
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+func (s *LEDServer) handleValidateLayout(w http.ResponseWriter, r *http.Request) {
+	report := s.controller.ValidateLayout()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
 }
 
 func (s *LEDServer) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "Healthy")
 }
 
+func (s *LEDServer) handleGetOutputs(w http.ResponseWriter, r *http.Request) {
+	if s.outputs == nil {
+		http.Error(w, "Outputs not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.outputs.Info())
+}
+
+// counts the frames each output dropped or sent late since startup
+func (s *LEDServer) handleGetOutputMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.outputs == nil {
+		http.Error(w, "Outputs not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.outputs.Metrics())
+}
+
+// starts the shutdown sequence. the body is optional, without it the configured fade is used.
+func (s *LEDServer) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	if s.shutdown == nil {
+		http.Error(w, "Shutdown not available", http.StatusNotFound)
+		return
+	}
+
+	var request ShutdownRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fade := s.shutdown.defaultFade
+	if request.Fade != nil {
+		if *request.Fade < 0 {
+			http.Error(w, "fade can't be negative", http.StatusBadRequest)
+			return
+		}
+		fade = time.Duration(*request.Fade) * time.Millisecond
+	}
+
+	if !s.shutdown.Request(fade) {
+		http.Error(w, "Already shutting down", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *LEDServer) handleGetInputs(w http.ResponseWriter, r *http.Request) {
+	if s.input == nil {
+		http.Error(w, "sACN input not enabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.input.Sources())
+}
+
+func (s *LEDServer) handleGetRecording(w http.ResponseWriter, r *http.Request) {
+	info, recording := s.controller.Recording()
+	if !recording {
+		http.Error(w, "Not recording", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// starts recording to a new file in the recording directory, named for the time
+func (s *LEDServer) handleStartRecording(w http.ResponseWriter, r *http.Request) {
+	dir := s.recordingDir
+	if dir == "" {
+		dir = "recordings"
+	}
+	info, err := s.controller.StartRecording(newCapturePath(dir))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+func (s *LEDServer) handleStopRecording(w http.ResponseWriter, r *http.Request) {
+	info, err := s.controller.StopRecording()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+func (s *LEDServer) handleGetControl(w http.ResponseWriter, r *http.Request) {
+	if s.control == nil {
+		http.Error(w, "Console control not enabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.control.Info())
+}
+
 func (s *LEDServer) handleUpdateTransition(w http.ResponseWriter, r *http.Request) {
 	var configReq TransitionConfigRequest
 	if err := json.NewDecoder(r.Body).Decode(&configReq); err != nil {
@@ -339,31 +538,160 @@ func (s *LEDServer) handleSetColorMask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := updateColorMaskParameters(r, mask); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.controller.SetColorMask(mask)
+	w.WriteHeader(http.StatusOK)
+}
+
+// applies the parameters in the request body to the mask, if there are any
+func updateColorMaskParameters(r *http.Request, mask ColorMaskPattern) error {
 	// only try to decode parameters if there's a request body
-	if r.ContentLength > 0 {
-		parameters := mask.GetPatternUpdateRequest()
+	if r.ContentLength <= 0 {
+		return nil
+	}
 
-		// Store previous parameters for logging
-		previousParams := parameters.GetParameters()
-		previousParamsJSON, _ := json.Marshal(previousParams)
+	parameters := mask.GetPatternUpdateRequest()
 
-		if err := json.NewDecoder(r.Body).Decode(&parameters); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+	// Store previous parameters for logging
+	previousParams := parameters.GetParameters()
+	previousParamsJSON, _ := json.Marshal(previousParams)
 
-		// Log the changes
-		newParamsJSON, _ := json.Marshal(parameters.GetParameters())
-		log.Printf("Color mask updated: %s\nPrevious parameters: %s\nNew parameters: %s",
-			maskName, previousParamsJSON, newParamsJSON)
+	if err := json.NewDecoder(r.Body).Decode(&parameters); err != nil {
+		return err
+	}
 
-		if err := mask.UpdateParameters(parameters.GetParameters()); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	// Log the changes
+	newParamsJSON, _ := json.Marshal(parameters.GetParameters())
+	log.Printf("Color mask updated: %s\nPrevious parameters: %s\nNew parameters: %s",
+		mask.GetName(), previousParamsJSON, newParamsJSON)
+
+	return mask.UpdateParameters(parameters.GetParameters())
+}
+
+func (s *LEDServer) handleGetGroups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.controller.Groups())
+}
+
+func (s *LEDServer) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var request SectionConfig
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	group, err := s.controller.AddGroup(request)
+	if err == ErrGroupExists {
+		http.Error(w, fmt.Sprintf("Group %s already exists", request.Name), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+func (s *LEDServer) handleGetZones(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.controller.Zones())
+}
+
+func (s *LEDServer) handleUpdateZonePattern(w http.ResponseWriter, r *http.Request) {
+	sectionName := r.PathValue("section")
+	patternName := r.PathValue("pattern")
+
+	// zones register the same patterns as the main controller. checking the name first
+	// keeps a typo from taking the section over
+	if _, exists := s.controller.patterns[patternName]; !exists {
+		http.Error(w, fmt.Sprintf("Pattern %s not found", patternName), http.StatusNotFound)
+		return
+	}
+
+	zone, err := s.controller.Zone(sectionName)
+	if err == ErrSectionNotFound {
+		http.Error(w, fmt.Sprintf("Section %s not found", sectionName), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pattern, exists := zone.controller.patterns[patternName]
+	if !exists {
+		http.Error(w, fmt.Sprintf("Pattern %s not found", patternName), http.StatusNotFound)
+		return
+	}
+
+	updateRequest, err := decodePatternUpdateRequest(r, pattern)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := zone.controller.UpdatePattern(patternName, updateRequest); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *LEDServer) handleSetZoneColorMask(w http.ResponseWriter, r *http.Request) {
+	sectionName := r.PathValue("section")
+	maskName := r.PathValue("mask")
+
+	if _, exists := s.colorMasks[maskName]; !exists {
+		http.Error(w, "Color mask not found", http.StatusNotFound)
+		return
+	}
+
+	zone, err := s.controller.Zone(sectionName)
+	if err == ErrSectionNotFound {
+		http.Error(w, fmt.Sprintf("Section %s not found", sectionName), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mask, exists := zone.colorMasks[maskName]
+	if !exists {
+		http.Error(w, "Color mask not found", http.StatusNotFound)
+		return
+	}
+
+	if err := updateColorMaskParameters(r, mask); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	zone.controller.SetColorMask(mask)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *LEDServer) handleRemoveZone(w http.ResponseWriter, r *http.Request) {
+	sectionName := r.PathValue("section")
+
+	err := s.controller.RemoveZone(sectionName)
+	if err == ErrZoneNotFound {
+		http.Error(w, fmt.Sprintf("No zone for section %s", sectionName), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	s.controller.SetColorMask(mask)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// the layout file describes the physical installation: which segments exist, how they are
+// generated, where they sit in the viewing area, and how they are wired to universes.
+// keeping this out of main means a leg can be rewired without recompiling.
+
+type LayoutConfig struct {
+	Name      string          `json:"name"`
+	Sections  []SectionConfig `json:"sections"`
+	Universes []uint16        `json:"universes,omitempty"`
+	Segments  []SegmentConfig `json:"segments"`
+	Strips    []StripConfig   `json:"strips,omitempty"`
+	Outputs   []OutputConfig  `json:"outputs,omitempty"`
+	DDP       []DDPConfig     `json:"ddp,omitempty"`
+	OPC       []OPCConfig     `json:"opc,omitempty"`
+	WLED      []WLEDConfig    `json:"wled,omitempty"`
+
+	// directory of the layout file, used to resolve relative import sources
+	baseDir string
+}
+
+type SectionConfig struct {
+	Name   string `json:"name"`
+	Label  string `json:"label"`
+	Parent string `json:"parent,omitempty"`
+
+	// pixels the section selects on top of the segments that list it (see groups.go)
+	Segments []string      `json:"segments,omitempty"`
+	Polygon  []RegionPoint `json:"polygon,omitempty"`
+	Circle   *CircleRegion `json:"circle,omitempty"`
+	Pixels   []PixelRange  `json:"pixels,omitempty"`
+}
+
+type SegmentConfig struct {
+	Name         string   `json:"name"`
+	Generator    string   `json:"generator"`
+	Universe     uint16   `json:"universe"`
+	StartChannel uint16   `json:"startChannel"`
+	X            int16    `json:"x"`
+	Y            int16    `json:"y"`
+	Z            int16    `json:"z,omitempty"`
+	ZEnd         *int16   `json:"zEnd,omitempty"` // when set, depth ramps from z to zEnd along the segment
+	Rotation     int16    `json:"rotation"`
+	PixelType    string   `json:"pixelType"`
+	ColorOrder   string   `json:"colorOrder"`
+	Sections     []string `json:"sections"`
+
+	// override the size and weight the generator gives each pixel
+	PixelSize   float64 `json:"pixelSize,omitempty"`
+	PixelWeight float64 `json:"pixelWeight,omitempty"`
+
+	// size and weight of each kind of pixel, for generators that build more than one kind
+	PixelKinds map[string]PixelKind `json:"pixelKinds,omitempty"`
+
+	// only used by generators that build a straight run of pixels
+	Count   int   `json:"count,omitempty"`
+	Spacing int16 `json:"spacing,omitempty"`
+
+	// only used by generators that import pixels from another tool's export
+	Source string        `json:"source,omitempty"`
+	Model  string        `json:"model,omitempty"`
+	Bounds *LayoutBounds `json:"bounds,omitempty"`
+	FlipY  bool          `json:"flipY,omitempty"`
+}
+
+// PixelKind is the footprint and light output of one kind of pixel in a segment. a zero size
+// is a point and a zero weight is 1.
+type PixelKind struct {
+	Size   float64 `json:"size,omitempty"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// rectangle in viewing-area coordinates that imported pixels are scaled into
+type LayoutBounds struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// PixelSegment records where a layout segment's pixels live in the pixel map
+type PixelSegment struct {
+	config SegmentConfig
+	start  int
+	count  int
+}
+
+// everything a generator needs to turn a segment definition into pixels
+type segmentBuild struct {
+	segment     SegmentConfig
+	sections    []Section
+	allSections map[string]Section
+	pixelType   PixelType
+	colorOrder  ColorOrder
+}
+
+type segmentGenerator func(build segmentBuild) (*[]Pixel, error)
+
+var segmentGenerators = map[string]segmentGenerator{
+	"mammoth": func(build segmentBuild) (*[]Pixel, error) {
+		segment := build.segment
+		big, small := segment.PixelKinds["big"], segment.PixelKinds["small"]
+		return buildMammothSegment(segment.Universe, segment.StartChannel, segment.X, segment.Y, segment.Rotation, big, small, build.sections, build.pixelType, build.colorOrder), nil
+	},
+	"tusk": func(build segmentBuild) (*[]Pixel, error) {
+		segment := build.segment
+		return buildTuskSegment(segment.Universe, segment.StartChannel, segment.X, segment.Y, segment.Rotation, build.sections, build.pixelType, build.colorOrder), nil
+	},
+	"line": func(build segmentBuild) (*[]Pixel, error) {
+		segment := build.segment
+		if segment.Count <= 0 {
+			return nil, fmt.Errorf("line generator requires a positive count")
+		}
+		return buildLineSegment(segment.Universe, segment.StartChannel, segment.X, segment.Y, segment.Rotation, segment.Count, segment.Spacing, build.sections, build.pixelType, build.colorOrder), nil
+	},
+	"csv":     importCSVSegment,
+	"xlights": importXLightsSegment,
+}
+
+func loadLayout(path string) (*LayoutConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layout file %s: %w", path, err)
+	}
+
+	var layout LayoutConfig
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil, fmt.Errorf("failed to parse layout file %s: %w", path, err)
+	}
+
+	if len(layout.Segments) == 0 {
+		return nil, fmt.Errorf("layout %s does not define any segments", path)
+	}
+	layout.baseDir = filepath.Dir(path)
+
+	return &layout, nil
+}
+
+func (l *LayoutConfig) buildSections() map[string]Section {
+	sections := make(map[string]Section)
+	for _, section := range l.Sections {
+		label := section.Label
+		if label == "" {
+			label = section.Name
+		}
+		sections[section.Name] = Section{name: section.Name, label: label, parent: section.Parent}
+	}
+	return sections
+}
+
+func (l *LayoutConfig) buildPixelMap(sections map[string]Section) (*PixelMap, error) {
+	pixels := []Pixel{}
+	segments := make([]PixelSegment, 0, len(l.Segments))
+
+	for _, segment := range l.Segments {
+		if segment.Source != "" && !filepath.IsAbs(segment.Source) {
+			segment.Source = filepath.Join(l.baseDir, segment.Source)
+		}
+
+		segmentPixels, err := buildSegment(segment, sections)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, PixelSegment{
+			config: segment,
+			start:  len(pixels),
+			count:  len(*segmentPixels),
+		})
+		pixels = append(pixels, *segmentPixels...)
+	}
+
+	pixelMap := &PixelMap{
+		pixels:   &pixels,
+		segments: segments,
+		sections: sections,
+		groups:   slices.Clone(l.Sections),
+		ddp:      l.DDP,
+		opc:      l.OPC,
+		wled:     l.WLED,
+	}
+
+	if err := pixelMap.checkDDP(); err != nil {
+		return nil, err
+	}
+	if err := pixelMap.checkOPC(); err != nil {
+		return nil, err
+	}
+	if err := pixelMap.checkWLED(); err != nil {
+		return nil, err
+	}
+
+	if err := checkSectionHierarchy(sections); err != nil {
+		return nil, err
+	}
+	for _, section := range l.Sections {
+		if err := pixelMap.checkGroup(section); err != nil {
+			return nil, err
+		}
+	}
+	pixelMap.applyGroups(0, len(pixels))
+
+	strips, err := buildStrips(pixelMap, l.Strips)
+	if err != nil {
+		return nil, err
+	}
+	pixelMap.strips = strips
+	pixelMap.computeGeometry()
+	pixelMap.computeSampling()
+
+	return pixelMap, nil
+}
+
+func buildSegment(segment SegmentConfig, sections map[string]Section) (*[]Pixel, error) {
+	generator, exists := segmentGenerators[segment.Generator]
+	if !exists {
+		return nil, fmt.Errorf("segment %s: unknown generator %q", segment.Name, segment.Generator)
+	}
+
+	pixelType, err := parsePixelType(segment.PixelType)
+	if err != nil {
+		return nil, fmt.Errorf("segment %s: %w", segment.Name, err)
+	}
+
+	colorOrder, err := parseColorOrder(segment.ColorOrder)
+	if err != nil {
+		return nil, fmt.Errorf("segment %s: %w", segment.Name, err)
+	}
+
+	segmentSections := make([]Section, 0, len(segment.Sections))
+	for _, name := range segment.Sections {
+		section, exists := sections[name]
+		if !exists {
+			return nil, fmt.Errorf("segment %s: unknown section %q", segment.Name, name)
+		}
+		segmentSections = append(segmentSections, section)
+	}
+
+	startChannel := segment.StartChannel
+	if startChannel == 0 {
+		startChannel = 1
+	}
+	segment.StartChannel = startChannel
+
+	pixels, err := generator(segmentBuild{
+		segment:     segment,
+		sections:    segmentSections,
+		allSections: sections,
+		pixelType:   pixelType,
+		colorOrder:  colorOrder,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("segment %s: %w", segment.Name, err)
+	}
+
+	applySegmentDepth(*pixels, segment)
+	for i := range *pixels {
+		if segment.PixelSize > 0 {
+			(*pixels)[i].size = segment.PixelSize
+		}
+		if segment.PixelWeight > 0 {
+			(*pixels)[i].weight = segment.PixelWeight
+		}
+	}
+	return pixels, nil
+}
+
+// generators work in two dimensions, so the segment's depth is applied afterwards. imported
+// pixels may already carry their own depth, which the segment's z offsets.
+func applySegmentDepth(pixels []Pixel, segment SegmentConfig) {
+	for i := range pixels {
+		z := float64(segment.Z)
+		if segment.ZEnd != nil && len(pixels) > 1 {
+			z += float64(*segment.ZEnd-segment.Z) * float64(i) / float64(len(pixels)-1)
+		}
+		pixels[i].z += int16(math.Round(z))
+	}
+}
+
+// returns the universes declared in the layout, or every universe referenced by a
+// segment when no explicit list is given. segments sent over DDP or to WLED nodes don't use
+// a universe.
+func (l *LayoutConfig) universeNumbers() []uint16 {
+	if len(l.Universes) > 0 {
+		return l.Universes
+	}
+
+	deviceSegments := deviceSegments(l.DDP, l.WLED)
+	seen := make(map[uint16]bool)
+	universes := []uint16{}
+	for _, segment := range l.Segments {
+		if !seen[segment.Universe] && !deviceSegments[segment.Name] {
+			seen[segment.Universe] = true
+			universes = append(universes, segment.Universe)
+		}
+	}
+	sort.Slice(universes, func(i, j int) bool { return universes[i] < universes[j] })
+	return universes
+}
+
+// OutputConfig overrides how a universe is sent. universes without one use the default
+// protocol, controller address and priority from the environment. an output can name a
+// segment instead of a universe, in which case it applies to the segment's universe.
+type OutputConfig struct {
+	Universe     uint16   `json:"universe,omitempty"`
+	Segment      string   `json:"segment,omitempty"`
+	Protocol     string   `json:"protocol,omitempty"`
+	Destinations []string `json:"destinations,omitempty"`
+	Priority     *uint8   `json:"priority,omitempty"`
+	Multicast    *bool    `json:"multicast,omitempty"`
+	Sync         *bool    `json:"sync,omitempty"`
+}
+
+// returns the universe an output applies to
+func (l *LayoutConfig) outputUniverse(output OutputConfig) (uint16, error) {
+	if output.Segment == "" {
+		return output.Universe, nil
+	}
+	if output.Universe != 0 {
+		return 0, fmt.Errorf("output for segment %s can't also name a universe", output.Segment)
+	}
+	for _, segment := range l.Segments {
+		if segment.Name == output.Segment {
+			if ddpSegments(l.DDP)[segment.Name] {
+				return 0, fmt.Errorf("output for segment %s, which is sent over ddp", segment.Name)
+			}
+			if deviceSegments(nil, l.WLED)[segment.Name] {
+				return 0, fmt.Errorf("output for segment %s, which is sent to a wled node", segment.Name)
+			}
+			return segment.Universe, nil
+		}
+	}
+	return 0, fmt.Errorf("output for segment %s, which isn't in the layout", output.Segment)
+}
+
+// returns how each universe in the layout is sent, starting from the defaults
+func (l *LayoutConfig) universeOutputs(defaults UniverseOutput) ([]UniverseOutput, error) {
+	universes := l.universeNumbers()
+	overrides := make(map[uint16]OutputConfig)
+	for _, output := range l.Outputs {
+		universe, err := l.outputUniverse(output)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(universes, universe) {
+			return nil, fmt.Errorf("output for universe %d, which isn't in the layout", universe)
+		}
+		if _, exists := overrides[universe]; exists {
+			return nil, fmt.Errorf("universe %d has more than one output", universe)
+		}
+		overrides[universe] = output
+	}
+
+	outputs := make([]UniverseOutput, 0, len(universes))
+	for _, universe := range universes {
+		output := defaults
+		output.Universe = universe
+		override := overrides[universe]
+		if override.Protocol != "" {
+			output.Protocol = strings.ToLower(override.Protocol)
+		}
+		if _, exists := outputFactories[output.Protocol]; !exists {
+			return nil, fmt.Errorf("universe %d: unknown output protocol %q", universe, output.Protocol)
+		}
+
+		// multicast is an sACN feature, so a default of on only applies to sACN universes
+		if override.Multicast != nil {
+			output.Multicast = *override.Multicast
+		} else if output.Protocol != PROTOCOL_SACN {
+			output.Multicast = false
+		}
+
+		// and so is synchronization, for controllers that support it
+		if override.Sync != nil {
+			output.Sync = *override.Sync
+		} else if output.Protocol != PROTOCOL_SACN {
+			output.Sync = false
+		}
+
+		// destinations replace the defaults rather than adding to them, so a universe can be
+		// moved to another controller. an empty list leaves only multicast.
+		if override.Destinations != nil {
+			output.Destinations = override.Destinations
+		}
+		if len(output.Destinations) == 0 && !output.Multicast {
+			return nil, fmt.Errorf("universe %d has no destinations", universe)
+		}
+		for i, destination := range output.Destinations {
+			if destination == "" || slices.Contains(output.Destinations[:i], destination) {
+				return nil, fmt.Errorf("universe %d: invalid or repeated destination %q", universe, destination)
+			}
+		}
+
+		if override.Priority != nil {
+			output.Priority = *override.Priority
+		}
+		if output.Priority > MaxPriority {
+			return nil, fmt.Errorf("universe %d: priority %d is above the maximum of %d", universe, output.Priority, MaxPriority)
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+func parsePixelType(value string) (PixelType, error) {
+	switch strings.ToLower(value) {
+	case "rgb":
+		return PixelRGB, nil
+	case "rgbw", "":
+		return PixelRGBW, nil
+	}
+	return 0, fmt.Errorf("unknown pixel type %q", value)
+}
+
+func parseColorOrder(value string) (ColorOrder, error) {
+	switch strings.ToUpper(value) {
+	case "RGB", "":
+		return RGB, nil
+	case "RBG":
+		return RBG, nil
+	case "BRG":
+		return BRG, nil
+	case "BGR":
+		return BGR, nil
+	case "GRB":
+		return GRB, nil
+	case "GBR":
+		return GBR, nil
+	}
+	return 0, fmt.Errorf("unknown color order %q", value)
+}
+
+// describes the full pixel map, including the wiring details the visualizer socket leaves out
+type LayoutSnapshot struct {
+	Geometry Geometry          `json:"geometry"`
+	Segments []SegmentSnapshot `json:"segments"`
+	Strips   []StripSnapshot   `json:"strips"`
+	Pixels   []PixelSnapshot   `json:"pixels"`
+}
+
+type StripSnapshot struct {
+	Name      string         `json:"name"`
+	Index     int            `json:"index"`
+	Length    int            `json:"length"`
+	Direction StripDirection `json:"direction"`
+	Segments  []string       `json:"segments"`
+}
+
+type SegmentSnapshot struct {
+	SegmentConfig
+	Start int `json:"start"`
+	Count int `json:"count"`
+}
+
+type PixelSnapshot struct {
+	Index      int      `json:"index"`
+	X          int16    `json:"x"`
+	Y          int16    `json:"y"`
+	Z          int16    `json:"z"`
+	Universe   uint16   `json:"universe"`
+	Channel    uint16   `json:"channel"`
+	PixelType  string   `json:"pixelType"`
+	ColorOrder string   `json:"colorOrder"`
+	Size       float64  `json:"size,omitempty"`
+	Weight     float64  `json:"weight,omitempty"`
+	Sections   []string `json:"sections"`
+	Segment    string   `json:"segment,omitempty"`
+}
+
+// SegmentUpdateRequest moves, rotates or re-addresses a segment. omitted fields are unchanged
+type SegmentUpdateRequest struct {
+	X            *int16  `json:"x,omitempty"`
+	Y            *int16  `json:"y,omitempty"`
+	Z            *int16  `json:"z,omitempty"`
+	Rotation     *int16  `json:"rotation,omitempty"`
+	Universe     *uint16 `json:"universe,omitempty"`
+	StartChannel *uint16 `json:"startChannel,omitempty"`
+	Force        bool    `json:"force,omitempty"` // apply even if the result fails validation
+}
+
+func (r SegmentUpdateRequest) apply(segment SegmentConfig) SegmentConfig {
+	if r.X != nil {
+		segment.X = *r.X
+	}
+	if r.Y != nil {
+		segment.Y = *r.Y
+	}
+	if r.Z != nil {
+		segment.Z = *r.Z
+	}
+	if r.Rotation != nil {
+		segment.Rotation = *r.Rotation
+	}
+	if r.Universe != nil {
+		segment.Universe = *r.Universe
+	}
+	if r.StartChannel != nil {
+		segment.StartChannel = *r.StartChannel
+	}
+	return segment
+}
+
+// generators that read pixel positions from a source file rather than from the segment
+var importedGenerators = map[string]bool{"csv": true, "xlights": true}
+
+// rejects moving or rotating a segment whose generator takes its positions from a source
+// file, since rebuilding it would put every pixel back where it was
+func (r SegmentUpdateRequest) checkGenerator(segment SegmentConfig) error {
+	if !importedGenerators[segment.Generator] {
+		return nil
+	}
+
+	ignored := []string{}
+	if r.X != nil {
+		ignored = append(ignored, "x")
+	}
+	if r.Y != nil {
+		ignored = append(ignored, "y")
+	}
+	if r.Rotation != nil {
+		ignored = append(ignored, "rotation")
+	}
+	if len(ignored) > 0 {
+		return fmt.Errorf("segment %s takes its positions from %s, so %s can't be changed",
+			segment.Name, segment.Source, strings.Join(ignored, ", "))
+	}
+	return nil
+}
+
+func (p *PixelMap) snapshot() LayoutSnapshot {
+	snapshot := LayoutSnapshot{
+		Geometry: p.geometry,
+		Segments: make([]SegmentSnapshot, 0, len(p.segments)),
+		Strips:   make([]StripSnapshot, 0, len(p.strips)),
+		Pixels:   make([]PixelSnapshot, 0, len(*p.pixels)),
+	}
+
+	for _, strip := range p.strips {
+		snapshot.Strips = append(snapshot.Strips, StripSnapshot{
+			Name:      strip.name,
+			Index:     strip.index,
+			Length:    strip.Len(),
+			Direction: strip.direction,
+			Segments:  strip.segments,
+		})
+	}
+
+	for _, segment := range p.segments {
+		snapshot.Segments = append(snapshot.Segments, SegmentSnapshot{
+			SegmentConfig: segment.config,
+			Start:         segment.start,
+			Count:         segment.count,
+		})
+	}
+
+	for i, pixel := range *p.pixels {
+		sections := make([]string, 0, len(pixel.sections))
+		for _, section := range pixel.sections {
+			sections = append(sections, section.name)
+		}
+
+		snapshot.Pixels = append(snapshot.Pixels, PixelSnapshot{
+			Index:      i,
+			X:          pixel.x,
+			Y:          pixel.y,
+			Z:          pixel.z,
+			Universe:   pixel.universe,
+			Channel:    pixel.channelPosition,
+			PixelType:  pixel.pixelType.String(),
+			ColorOrder: pixel.colorOrder.String(),
+			Size:       pixel.size,
+			Weight:     pixel.weight,
+			Sections:   sections,
+			Segment:    p.segmentNameAt(i),
+		})
+	}
+
+	return snapshot
+}
+
+func (p *PixelMap) findSegment(name string) (int, error) {
+	for i, segment := range p.segments {
+		if segment.config.Name == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("segment %s not found", name)
+}
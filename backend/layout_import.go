@@ -0,0 +1,408 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// importers turn layouts exported from other mapping tools into pixels. exports use their own
+// coordinate systems, so imported points are scaled into the viewing area before they become
+// pixels, preserving aspect ratio.
+
+// a pixel position read from an export, before it's been normalized into the viewing area
+type importedPixel struct {
+	x, y, z         float64
+	universe        uint16
+	channelPosition uint16
+	sections        []Section
+	size, weight    float64
+}
+
+func importCSVSegment(build segmentBuild) (*[]Pixel, error) {
+	file, err := os.Open(build.segment.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open csv source: %w", err)
+	}
+	defer file.Close()
+
+	imported, err := readCSVPixels(file, build.allSections, build.pixelType)
+	if err != nil {
+		return nil, err
+	}
+
+	// rows can name their own sections, but always belong to the segment's sections too
+	for i := range imported {
+		imported[i].sections = mergeSections(build.sections, imported[i].sections)
+	}
+
+	return normalizeImportedPixels(imported, build), nil
+}
+
+// reads rows of x,y,universe,channel,section,z,size,weight. channel is the pixel's first DMX
+// channel in its universe, as xLights and most other tools export it. the section column is
+// optional and may list several sections separated by "|", z may be left off for flat layouts,
+// and size (in the export's units) and weight may be left off for uniform pixels. a header row
+// is skipped if present.
+func readCSVPixels(r io.Reader, sections map[string]Section, pixelType PixelType) ([]importedPixel, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	imported := []importedPixel{}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// parse errors carry their own line number
+			return nil, fmt.Errorf("csv source: %w", err)
+		}
+		// comments and blank lines are skipped, so records aren't lines
+		line, _ := reader.FieldPos(0)
+
+		if len(record) < 4 {
+			return nil, fmt.Errorf("csv line %d: expected at least 4 columns, got %d", line, len(record))
+		}
+
+		x, err := strconv.ParseFloat(record[0], 64)
+		if err != nil {
+			if first {
+				// header row
+				continue
+			}
+			return nil, fmt.Errorf("csv line %d: invalid x %q", line, record[0])
+		}
+		y, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("csv line %d: invalid y %q", line, record[1])
+		}
+		universe, err := strconv.ParseUint(record[2], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("csv line %d: invalid universe %q", line, record[2])
+		}
+		channel, err := strconv.ParseUint(record[3], 10, 16)
+		if err != nil || channel == 0 || channel > UniverseChannels {
+			return nil, fmt.Errorf("csv line %d: invalid channel %q", line, record[3])
+		}
+
+		pixel := importedPixel{
+			x:               x,
+			y:               y,
+			universe:        uint16(universe),
+			channelPosition: uint16((int(channel)-1)/int(pixelType) + 1), // the pixel slot the channel falls in
+		}
+
+		if len(record) > 4 && record[4] != "" {
+			for _, name := range strings.Split(record[4], "|") {
+				name = strings.TrimSpace(name)
+				section, exists := sections[name]
+				if !exists {
+					return nil, fmt.Errorf("csv line %d: unknown section %q", line, name)
+				}
+				pixel.sections = append(pixel.sections, section)
+			}
+		}
+
+		if len(record) > 5 && record[5] != "" {
+			pixel.z, err = strconv.ParseFloat(record[5], 64)
+			if err != nil {
+				return nil, fmt.Errorf("csv line %d: invalid z %q", line, record[5])
+			}
+		}
+
+		if len(record) > 6 && record[6] != "" {
+			pixel.size, err = strconv.ParseFloat(record[6], 64)
+			if err != nil || pixel.size < 0 {
+				return nil, fmt.Errorf("csv line %d: invalid size %q", line, record[6])
+			}
+		}
+
+		if len(record) > 7 && record[7] != "" {
+			pixel.weight, err = strconv.ParseFloat(record[7], 64)
+			if err != nil || pixel.weight < 0 {
+				return nil, fmt.Errorf("csv line %d: invalid weight %q", line, record[7])
+			}
+		}
+
+		imported = append(imported, pixel)
+	}
+
+	if len(imported) == 0 {
+		return nil, fmt.Errorf("csv source contains no pixels")
+	}
+	return imported, nil
+}
+
+// the subset of an xLights model definition we care about. this matches both the <model>
+// elements in xlights_rgbeffects.xml and the <custommodel> root of an exported .xmodel file
+type xLightsModel struct {
+	Name                  string `xml:"name,attr"`
+	DisplayAs             string `xml:"DisplayAs,attr"`
+	StartChannel          string `xml:"StartChannel,attr"`
+	CustomModel           string `xml:"CustomModel,attr"`
+	CustomModelCompressed string `xml:"CustomModelCompressed,attr"`
+	Parm1                 int    `xml:"parm1,attr"`
+	Parm2                 int    `xml:"parm2,attr"`
+}
+
+type xLightsRGBEffects struct {
+	Models []xLightsModel `xml:"models>model"`
+}
+
+func importXLightsSegment(build segmentBuild) (*[]Pixel, error) {
+	data, err := os.ReadFile(build.segment.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xLights source: %w", err)
+	}
+
+	model, err := findXLightsModel(data, build.segment.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := readXLightsModel(model, build.segment, build.pixelType)
+	if err != nil {
+		return nil, fmt.Errorf("xLights model %s: %w", model.Name, err)
+	}
+	for i := range imported {
+		imported[i].sections = build.sections
+	}
+
+	return normalizeImportedPixels(imported, build), nil
+}
+
+func findXLightsModel(data []byte, name string) (xLightsModel, error) {
+	// exported .xmodel files hold a single model at the root
+	var single xLightsModel
+	if err := xml.Unmarshal(data, &single); err == nil && (single.CustomModel != "" || single.CustomModelCompressed != "") {
+		if name == "" || single.Name == name {
+			return single, nil
+		}
+	}
+
+	var effects xLightsRGBEffects
+	if err := xml.Unmarshal(data, &effects); err != nil {
+		return xLightsModel{}, fmt.Errorf("failed to parse xLights source: %w", err)
+	}
+	for _, model := range effects.Models {
+		if model.Name == name || (name == "" && len(effects.Models) == 1) {
+			return model, nil
+		}
+	}
+
+	if name == "" {
+		return xLightsModel{}, fmt.Errorf("xLights source contains %d models, a model name is required", len(effects.Models))
+	}
+	return xLightsModel{}, fmt.Errorf("xLights model %q not found", name)
+}
+
+func readXLightsModel(model xLightsModel, segment SegmentConfig, pixelType PixelType) ([]importedPixel, error) {
+	// node number -> grid position as column, row, layer
+	nodes := make(map[int][3]float64)
+
+	switch {
+	case model.CustomModelCompressed != "":
+		// "node,row,col[,layer];..."
+		for _, entry := range strings.Split(model.CustomModelCompressed, ";") {
+			fields := strings.Split(entry, ",")
+			if len(fields) < 3 {
+				continue
+			}
+			node, errNode := strconv.Atoi(fields[0])
+			row, errRow := strconv.Atoi(fields[1])
+			col, errCol := strconv.Atoi(fields[2])
+			layer := 0
+			var errLayer error
+			if len(fields) > 3 {
+				layer, errLayer = strconv.Atoi(fields[3])
+			}
+			if errNode != nil || errRow != nil || errCol != nil || errLayer != nil {
+				return nil, fmt.Errorf("invalid CustomModelCompressed entry %q", entry)
+			}
+			nodes[node] = [3]float64{float64(col), float64(row), float64(layer)}
+		}
+	case model.CustomModel != "":
+		// rows separated by ";", columns by ",", layers by "|". layers become depth
+		for layer, cells := range strings.Split(model.CustomModel, "|") {
+			for row, columns := range strings.Split(cells, ";") {
+				for col, cell := range strings.Split(columns, ",") {
+					if cell == "" {
+						continue
+					}
+					node, err := strconv.Atoi(cell)
+					if err != nil {
+						return nil, fmt.Errorf("invalid node %q in CustomModel", cell)
+					}
+					nodes[node] = [3]float64{float64(col), float64(row), float64(layer)}
+				}
+			}
+		}
+	case model.DisplayAs == "Single Line":
+		// parm1 strings of parm2 nodes each, laid out left to right
+		for node := 1; node <= model.Parm1*model.Parm2; node++ {
+			nodes[node] = [3]float64{float64(node - 1), 0, 0}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported model type %q, export it as a custom model", model.DisplayAs)
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("model contains no nodes")
+	}
+
+	universe, channelPosition, err := parseXLightsStartChannel(model.StartChannel, segment, pixelType)
+	if err != nil {
+		return nil, err
+	}
+
+	maxNode := 0
+	for node := range nodes {
+		maxNode = max(maxNode, node)
+	}
+
+	pixelsPerUniverse := uint16(UniverseChannels / int(pixelType))
+	imported := make([]importedPixel, 0, len(nodes))
+	for node := 1; node <= maxNode; node++ {
+		position, exists := nodes[node]
+		if exists {
+			imported = append(imported, importedPixel{
+				x:               position[0],
+				y:               position[1],
+				z:               position[2],
+				universe:        universe,
+				channelPosition: channelPosition,
+			})
+		}
+
+		// nodes roll over into the next universe once the current one is full
+		channelPosition++
+		if channelPosition > pixelsPerUniverse {
+			universe++
+			channelPosition = 1
+		}
+	}
+
+	return imported, nil
+}
+
+// xLights start channels are either "#universe:channel" or an absolute channel number counted
+// from the segment's universe. the segment's own universe/startChannel are used when the model
+// doesn't say. returns the universe
+// and the 1-based pixel slot within it.
+func parseXLightsStartChannel(value string, segment SegmentConfig, pixelType PixelType) (uint16, uint16, error) {
+	channelsPerUniverse := (UniverseChannels / int(pixelType)) * int(pixelType)
+
+	var universe, channel int
+	switch {
+	case value == "":
+		return segment.Universe, segment.StartChannel, nil
+	case strings.HasPrefix(value, "#"):
+		parts := strings.SplitN(strings.TrimPrefix(value, "#"), ":", 2)
+		if len(parts) != 2 {
+			return 0, 0, fmt.Errorf("invalid start channel %q", value)
+		}
+		var errUniverse, errChannel error
+		universe, errUniverse = strconv.Atoi(parts[0])
+		channel, errChannel = strconv.Atoi(parts[1])
+		if errUniverse != nil || errChannel != nil || channel < 1 {
+			return 0, 0, fmt.Errorf("invalid start channel %q", value)
+		}
+		// channels past the end of the universe carry on into the next one
+		universe += (channel - 1) / channelsPerUniverse
+		channel = (channel-1)%channelsPerUniverse + 1
+	default:
+		absolute, err := strconv.Atoi(value)
+		if err != nil || absolute < 1 {
+			return 0, 0, fmt.Errorf("unsupported start channel %q", value)
+		}
+		universe = int(segment.Universe) + (absolute-1)/channelsPerUniverse
+		channel = (absolute-1)%channelsPerUniverse + 1
+	}
+
+	return uint16(universe), uint16((channel-1)/int(pixelType) + 1), nil
+}
+
+// scales imported positions into the segment's bounds (the whole viewing area by default),
+// keeping the aspect ratio and centering the result
+func normalizeImportedPixels(imported []importedPixel, build segmentBuild) *[]Pixel {
+	bounds := LayoutBounds{X: MIN_X, Y: MIN_Y, Width: MAX_X - MIN_X, Height: MAX_Y - MIN_Y}
+	if build.segment.Bounds != nil {
+		bounds = *build.segment.Bounds
+	}
+
+	minX, minY, minZ := math.MaxFloat64, math.MaxFloat64, math.MaxFloat64
+	maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
+	for _, pixel := range imported {
+		minX = math.Min(minX, pixel.x)
+		maxX = math.Max(maxX, pixel.x)
+		minY = math.Min(minY, pixel.y)
+		maxY = math.Max(maxY, pixel.y)
+		minZ = math.Min(minZ, pixel.z)
+	}
+
+	spanX := maxX - minX
+	spanY := maxY - minY
+	scale := 1.0
+	if spanX > 0 || spanY > 0 {
+		scale = math.Min(safeDivide(bounds.Width, spanX), safeDivide(bounds.Height, spanY))
+	}
+	offsetX := bounds.X + (bounds.Width-spanX*scale)/2
+	offsetY := bounds.Y + (bounds.Height-spanY*scale)/2
+
+	pixels := make([]Pixel, 0, len(imported))
+	for _, pixel := range imported {
+		y := pixel.y - minY
+		if build.segment.FlipY {
+			y = spanY - y
+		}
+		pixels = append(pixels, Pixel{
+			x:               int16(math.Round(offsetX + (pixel.x-minX)*scale)),
+			y:               int16(math.Round(offsetY + y*scale)),
+			z:               int16(math.Round((pixel.z - minZ) * scale)),
+			size:            pixel.size * scale,
+			weight:          pixel.weight,
+			universe:        pixel.universe,
+			channelPosition: pixel.channelPosition,
+			sections:        pixel.sections,
+			pixelType:       build.pixelType,
+			color:           Color{R: 0, G: 0, B: 0, W: 0},
+			colorOrder:      build.colorOrder,
+		})
+	}
+	return &pixels
+}
+
+// divides, treating a zero-length span as unconstrained
+func safeDivide(size float64, span float64) float64 {
+	if span == 0 {
+		return math.MaxFloat64
+	}
+	return size / span
+}
+
+func mergeSections(base []Section, extra []Section) []Section {
+	merged := make([]Section, 0, len(base)+len(extra))
+	merged = append(merged, base...)
+	for _, section := range extra {
+		exists := false
+		for _, existing := range merged {
+			if existing.name == section.name {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			merged = append(merged, section)
+		}
+	}
+	return merged
+}
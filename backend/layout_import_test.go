@@ -0,0 +1,240 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadCSVPixels(t *testing.T) {
+	tests := []struct {
+		name      string
+		csv       string
+		pixelType PixelType
+		want      [][2]uint16 // universe, pixel slot
+		err       string
+	}{
+		{
+			name:      "header is skipped",
+			csv:       "x,y,universe,channel\n0,0,1,1\n1,0,1,4\n",
+			pixelType: PixelRGB,
+			want:      [][2]uint16{{1, 1}, {1, 2}},
+		},
+		{
+			name:      "header after a comment is skipped",
+			csv:       "# exported from xLights\nx,y,universe,channel\n0,0,1,1\n",
+			pixelType: PixelRGB,
+			want:      [][2]uint16{{1, 1}},
+		},
+		{
+			name:      "bad header-like row after the first is an error",
+			csv:       "0,0,1,1\nx,y,universe,channel\n",
+			pixelType: PixelRGB,
+			err:       "csv line 2: invalid x",
+		},
+		{
+			name:      "comments and blank lines are counted in line numbers",
+			csv:       "# exported\nx,y,universe,channel\n\n# legs\n0,0,1,1\n0,0,1,nope\n",
+			pixelType: PixelRGB,
+			err:       "csv line 6: invalid channel",
+		},
+		{
+			name:      "rgb channels become pixel slots",
+			csv:       "0,0,1,1\n0,0,1,4\n0,0,1,7\n0,0,2,508\n",
+			pixelType: PixelRGB,
+			want:      [][2]uint16{{1, 1}, {1, 2}, {1, 3}, {2, 170}},
+		},
+		{
+			name:      "rgbw channels become pixel slots",
+			csv:       "0,0,1,1\n0,0,1,5\n0,0,1,9\n0,0,2,509\n",
+			pixelType: PixelRGBW,
+			want:      [][2]uint16{{1, 1}, {1, 2}, {1, 3}, {2, 128}},
+		},
+		{
+			name:      "channel inside a pixel falls in that pixel's slot",
+			csv:       "0,0,1,3\n0,0,1,8\n",
+			pixelType: PixelRGBW,
+			want:      [][2]uint16{{1, 1}, {1, 2}},
+		},
+		{
+			name:      "channel past the universe is an error",
+			csv:       "0,0,1,513\n",
+			pixelType: PixelRGB,
+			err:       "csv line 1: invalid channel",
+		},
+		{
+			name:      "only a header is an error",
+			csv:       "x,y,universe,channel\n",
+			pixelType: PixelRGB,
+			err:       "contains no pixels",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			imported, err := readCSVPixels(strings.NewReader(test.csv), nil, test.pixelType)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(imported) != len(test.want) {
+				t.Fatalf("got %d pixels, want %d", len(imported), len(test.want))
+			}
+			for i, want := range test.want {
+				if got := [2]uint16{imported[i].universe, imported[i].channelPosition}; got != want {
+					t.Errorf("pixel %d: got universe %d slot %d, want universe %d slot %d", i, got[0], got[1], want[0], want[1])
+				}
+			}
+		})
+	}
+}
+
+func TestParseXLightsStartChannel(t *testing.T) {
+	segment := SegmentConfig{Universe: 2, StartChannel: 5}
+	tests := []struct {
+		value     string
+		pixelType PixelType
+		universe  uint16
+		slot      uint16
+		wantErr   bool
+	}{
+		{value: "", pixelType: PixelRGB, universe: 2, slot: 5},
+		{value: "#3:1", pixelType: PixelRGB, universe: 3, slot: 1},
+		{value: "#3:4", pixelType: PixelRGB, universe: 3, slot: 2},
+		{value: "#3:508", pixelType: PixelRGB, universe: 3, slot: 170},
+		{value: "#3:511", pixelType: PixelRGB, universe: 4, slot: 1}, // rgb universes hold 510 channels
+		{value: "#3:1024", pixelType: PixelRGB, universe: 5, slot: 2},
+		{value: "#3:509", pixelType: PixelRGBW, universe: 3, slot: 128},
+		{value: "#3:513", pixelType: PixelRGBW, universe: 4, slot: 1},
+		{value: "1", pixelType: PixelRGB, universe: 2, slot: 1},
+		{value: "511", pixelType: PixelRGB, universe: 3, slot: 1},
+		{value: "1021", pixelType: PixelRGB, universe: 4, slot: 1},
+		{value: "517", pixelType: PixelRGBW, universe: 3, slot: 2},
+		{value: "#3", pixelType: PixelRGB, wantErr: true},
+		{value: "#3:0", pixelType: PixelRGB, wantErr: true},
+		{value: "0", pixelType: PixelRGB, wantErr: true},
+		{value: "!controller:1", pixelType: PixelRGB, wantErr: true},
+	}
+
+	for _, test := range tests {
+		universe, slot, err := parseXLightsStartChannel(test.value, segment, test.pixelType)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q (%v): expected an error", test.value, test.pixelType)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q (%v): %v", test.value, test.pixelType, err)
+			continue
+		}
+		if universe != test.universe || slot != test.slot {
+			t.Errorf("%q (%v): got universe %d slot %d, want universe %d slot %d", test.value, test.pixelType, universe, slot, test.universe, test.slot)
+		}
+	}
+}
+
+func TestReadXLightsModelRollsOverUniverses(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     xLightsModel
+		pixelType PixelType
+		want      [][2]uint16 // universe, pixel slot
+	}{
+		{
+			name:      "rgb line starting near the end of a universe",
+			model:     xLightsModel{DisplayAs: "Single Line", Parm1: 1, Parm2: 4, StartChannel: "#1:505"},
+			pixelType: PixelRGB,
+			want:      [][2]uint16{{1, 169}, {1, 170}, {2, 1}, {2, 2}},
+		},
+		{
+			name:      "rgbw line from an absolute start channel",
+			model:     xLightsModel{DisplayAs: "Single Line", Parm1: 1, Parm2: 3, StartChannel: "509"},
+			pixelType: PixelRGBW,
+			want:      [][2]uint16{{1, 128}, {2, 1}, {2, 2}},
+		},
+		{
+			name:      "missing nodes still take their channels",
+			model:     xLightsModel{CustomModel: "1,,4", StartChannel: "#1:508"},
+			pixelType: PixelRGB,
+			want:      [][2]uint16{{1, 170}, {2, 3}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			imported, err := readXLightsModel(test.model, SegmentConfig{Universe: 1, StartChannel: 1}, test.pixelType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(imported) != len(test.want) {
+				t.Fatalf("got %d pixels, want %d", len(imported), len(test.want))
+			}
+			for i, want := range test.want {
+				if got := [2]uint16{imported[i].universe, imported[i].channelPosition}; got != want {
+					t.Errorf("pixel %d: got universe %d slot %d, want universe %d slot %d", i, got[0], got[1], want[0], want[1])
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeImportedPixels(t *testing.T) {
+	tests := []struct {
+		name     string
+		imported []importedPixel
+		bounds   *LayoutBounds
+		want     [][2]int16
+	}{
+		{
+			name:     "single point is centered",
+			imported: []importedPixel{{x: 12, y: -7}},
+			want:     [][2]int16{{400, 400}},
+		},
+		{
+			name:     "single point is centered in the segment's bounds",
+			imported: []importedPixel{{x: 12, y: -7}},
+			bounds:   &LayoutBounds{X: 100, Y: 200, Width: 50, Height: 10},
+			want:     [][2]int16{{125, 205}},
+		},
+		{
+			name:     "horizontal line spans the width",
+			imported: []importedPixel{{x: 0, y: 5}, {x: 5, y: 5}, {x: 10, y: 5}},
+			want:     [][2]int16{{0, 400}, {400, 400}, {800, 400}},
+		},
+		{
+			name:     "vertical line spans the height",
+			imported: []importedPixel{{x: 3, y: -1}, {x: 3, y: 1}},
+			want:     [][2]int16{{400, 0}, {400, 800}},
+		},
+		{
+			name:     "coincident points stay together",
+			imported: []importedPixel{{x: 2, y: 2}, {x: 2, y: 2}},
+			want:     [][2]int16{{400, 400}, {400, 400}},
+		},
+		{
+			name:     "aspect ratio is kept",
+			imported: []importedPixel{{x: 0, y: 0}, {x: 4, y: 2}},
+			want:     [][2]int16{{0, 200}, {800, 600}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			build := segmentBuild{segment: SegmentConfig{Bounds: test.bounds}, pixelType: PixelRGB}
+			pixels := *normalizeImportedPixels(test.imported, build)
+			if len(pixels) != len(test.want) {
+				t.Fatalf("got %d pixels, want %d", len(pixels), len(test.want))
+			}
+			for i, want := range test.want {
+				if got := [2]int16{pixels[i].x, pixels[i].y}; got != want {
+					t.Errorf("pixel %d: got %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// the output path quietly drops pixels it can't place, so mapping mistakes otherwise only show
+// up as dark or doubled pixels on the sculpture. the validator catches them before we start.
+
+type LayoutIssueSeverity string
+
+const (
+	LAYOUT_ERROR   LayoutIssueSeverity = "error"
+	LAYOUT_WARNING LayoutIssueSeverity = "warning"
+)
+
+type LayoutIssue struct {
+	Severity LayoutIssueSeverity `json:"severity"`
+	Kind     string              `json:"kind"`
+	Message  string              `json:"message"`
+	Universe uint16              `json:"universe,omitempty"`
+	Channel  int                 `json:"channel,omitempty"` // channel position of the offending pixel
+	Segment  string              `json:"segment,omitempty"`
+}
+
+type LayoutReport struct {
+	Valid    bool          `json:"valid"`
+	Pixels   int           `json:"pixels"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Issues   []LayoutIssue `json:"issues"`
+}
+
+func (r *LayoutReport) add(issue LayoutIssue) {
+	if issue.Severity == LAYOUT_ERROR {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// returns the name of the segment the pixel at the given index was built from
+func (p *PixelMap) segmentNameAt(index int) string {
+	for _, segment := range p.segments {
+		if index >= segment.start && index < segment.start+segment.count {
+			return segment.config.Name
+		}
+	}
+	return ""
+}
+
+func validateLayout(pixelMap *PixelMap, universes []uint16) LayoutReport {
+	report := LayoutReport{
+		Pixels: len(*pixelMap.pixels),
+		Issues: []LayoutIssue{},
+	}
+
+	active := make(map[uint16]bool)
+	for _, universe := range universes {
+		active[universe] = true
+	}
+
+	// index of the pixel occupying each DMX channel, per universe
+	occupied := make(map[uint16]*[UniverseChannels]int)
+	pixelCounts := make(map[uint16]int)
+	unreachable := make(map[uint16]int)
+	deviceSegments := deviceSegments(pixelMap.ddp, pixelMap.wled)
+
+	for i, pixel := range *pixelMap.pixels {
+		segment := pixelMap.segmentNameAt(i)
+
+		if len(pixel.sections) == 0 {
+			report.add(LayoutIssue{
+				Severity: LAYOUT_WARNING,
+				Kind:     "noSection",
+				Message:  fmt.Sprintf("pixel %d does not belong to any section", i),
+				Universe: pixel.universe,
+				Channel:  int(pixel.channelPosition),
+				Segment:  segment,
+			})
+		}
+
+		// DDP devices and WLED nodes address pixels by offset, so universes and channels
+		// don't apply
+		if deviceSegments[segment] {
+			continue
+		}
+
+		pixelCounts[pixel.universe]++
+		if !active[pixel.universe] {
+			unreachable[pixel.universe]++
+		}
+
+		channelsPerPixel := int(pixel.pixelType)
+		if pixel.channelPosition == 0 {
+			report.add(LayoutIssue{
+				Severity: LAYOUT_ERROR,
+				Kind:     "overflow",
+				Message:  fmt.Sprintf("pixel %d has channel position 0, positions start at 1", i),
+				Universe: pixel.universe,
+				Segment:  segment,
+			})
+			continue
+		}
+
+		start := (int(pixel.channelPosition) - 1) * channelsPerPixel
+		end := start + channelsPerPixel - 1
+		if end >= UniverseChannels {
+			report.add(LayoutIssue{
+				Severity: LAYOUT_ERROR,
+				Kind:     "overflow",
+				Message:  fmt.Sprintf("pixel %d uses DMX channels %d-%d, past the end of the universe", i, start+1, end+1),
+				Universe: pixel.universe,
+				Channel:  int(pixel.channelPosition),
+				Segment:  segment,
+			})
+			continue
+		}
+
+		channels, exists := occupied[pixel.universe]
+		if !exists {
+			channels = &[UniverseChannels]int{}
+			for c := range channels {
+				channels[c] = -1
+			}
+			occupied[pixel.universe] = channels
+		}
+
+		for c := start; c <= end; c++ {
+			if other := channels[c]; other >= 0 {
+				report.add(LayoutIssue{
+					Severity: LAYOUT_ERROR,
+					Kind:     "overlap",
+					Message:  fmt.Sprintf("pixel %d overlaps pixel %d at DMX channel %d", i, other, c+1),
+					Universe: pixel.universe,
+					Channel:  int(pixel.channelPosition),
+					Segment:  segment,
+				})
+				break
+			}
+			channels[c] = i
+		}
+	}
+
+	for _, segment := range pixelMap.segments {
+		if segment.count > MAX_PIXEL_LENGTH {
+			report.add(LayoutIssue{
+				Severity: LAYOUT_ERROR,
+				Kind:     "segmentLength",
+				Message:  fmt.Sprintf("segment has %d pixels, controller limit is %d", segment.count, MAX_PIXEL_LENGTH),
+				Universe: segment.config.Universe,
+				Segment:  segment.config.Name,
+			})
+		}
+	}
+
+	for _, universe := range sortedUniverses(occupied) {
+		for _, gap := range findChannelGaps(occupied[universe]) {
+			message := fmt.Sprintf("DMX channels %d-%d are unused between pixels", gap[0]+1, gap[1]+1)
+			if gap[0] == 0 {
+				message = fmt.Sprintf("DMX channels %d-%d are unused before the first pixel", gap[0]+1, gap[1]+1)
+			}
+			report.add(LayoutIssue{
+				Severity: LAYOUT_WARNING,
+				Kind:     "gap",
+				Message:  message,
+				Universe: universe,
+			})
+		}
+	}
+
+	for _, universe := range universes {
+		if pixelCounts[universe] == 0 {
+			report.add(LayoutIssue{
+				Severity: LAYOUT_WARNING,
+				Kind:     "emptyUniverse",
+				Message:  "universe is active but has no pixels",
+				Universe: universe,
+			})
+		}
+	}
+
+	for _, universe := range sortedUniverses(unreachable) {
+		report.add(LayoutIssue{
+			Severity: LAYOUT_WARNING,
+			Kind:     "inactiveUniverse",
+			Message:  fmt.Sprintf("%d pixels are assigned to a universe that is not active", unreachable[universe]),
+			Universe: universe,
+		})
+	}
+
+	report.Valid = report.Errors == 0
+	return report
+}
+
+// returns the [first, last] channel ranges that are unused before the last used channel of a
+// universe, including any before its first pixel. trailing channels after the last pixel
+// aren't a gap.
+func findChannelGaps(channels *[UniverseChannels]int) [][2]int {
+	gaps := [][2]int{}
+	last := -1
+	for c, owner := range channels {
+		if owner < 0 {
+			continue
+		}
+		if c > last+1 {
+			gaps = append(gaps, [2]int{last + 1, c - 1})
+		}
+		last = c
+	}
+	return gaps
+}
+
+func sortedUniverses[V any](universes map[uint16]V) []uint16 {
+	sorted := make([]uint16, 0, len(universes))
+	for universe := range universes {
+		sorted = append(sorted, universe)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
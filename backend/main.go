@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -21,94 +22,109 @@ func main() {
 	ch := make(chan *PixelMap)
 	defer close(ch)
 
-	sections := map[string]Section{
-		"all":   {name: "all", label: "All"},
-		"limbs": {name: "limbs", label: "Limbs"},
-		"torso": {name: "torso", label: "Torso"},
-		"head":  {name: "head", label: "Head"},
-		"tusks": {name: "tusks", label: "Tusks"},
-	}
-
-	limb_sections := []Section{
-		sections["all"],
-		sections["limbs"],
+	layout, err := loadLayout(config.LayoutFile)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// define pixel types for different segments
-	limbPixelType := PixelRGBW
-	torsoPixelType := PixelRGBW
-	headPixelType := PixelRGBW
-	tuskPixelType := PixelRGB
+	sections := layout.buildSections()
 
-	// left front leg
-	pixels := buildMammothSegment(1, 1, 350, 500, 180, limb_sections, limbPixelType, RGB)
-	*pixels = append(*pixels, *buildMammothSegment(1, 21, 250, 500, 180, limb_sections, limbPixelType, RGB)...)
-	*pixels = append(*pixels, *buildMammothSegment(1, 41, 150, 500, 180, limb_sections, limbPixelType, RGB)...)
+	pixelMap, err := layout.buildPixelMap(sections)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded layout %q with %d segments and %d pixels", layout.Name, len(pixelMap.segments), len(*pixelMap.pixels))
 
-	// right front leg
-	*pixels = append(*pixels, *buildMammothSegment(2, 1, 450, 490, 0, limb_sections, limbPixelType, RGB)...)
-	*pixels = append(*pixels, *buildMammothSegment(2, 21, 550, 490, 0, limb_sections, limbPixelType, RGB)...)
-	*pixels = append(*pixels, *buildMammothSegment(2, 41, 650, 490, 0, limb_sections, limbPixelType, RGB)...)
+	report := validateLayout(pixelMap, layout.universeNumbers())
+	for _, issue := range report.Issues {
+		log.Printf("Layout %s (%s) universe %d segment %q: %s", issue.Severity, issue.Kind, issue.Universe, issue.Segment, issue.Message)
+	}
+	if !report.Valid {
+		if !config.AllowInvalidLayout {
+			log.Fatalf("layout has %d errors, set ALLOW_INVALID_LAYOUT=true to start anyway", report.Errors)
+		}
+		log.Printf("Warning: starting with %d layout errors", report.Errors)
+	}
 
-	// left rear leg
-	*pixels = append(*pixels, *buildMammothSegment(3, 1, 350, 190, 135, limb_sections, limbPixelType, RGB)...)
-	*pixels = append(*pixels, *buildMammothSegment(3, 21, 270, 110, 135, limb_sections, limbPixelType, RGB)...)
+	outputs, err := layout.universeOutputs(UniverseOutput{
+		Protocol:     config.OutputProtocol,
+		Destinations: config.ControllerAddresses,
+		Priority:     config.OutputPriority,
+		Multicast:    config.SACNMulticast,
+		Sync:         config.SyncUniverse != 0,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// right rear leg
-	*pixels = append(*pixels, *buildMammothSegment(4, 1, 450, 190, 45, limb_sections, limbPixelType, RGB)...)
-	*pixels = append(*pixels, *buildMammothSegment(4, 21, 530, 110, 45, limb_sections, limbPixelType, RGB)...)
+	handler := NewOutputHandler(OutputSettings{
+		SourceName:         config.SourceName,
+		CID:                config.CID,
+		Discovery:          config.SACNDiscovery,
+		MulticastInterface: config.MulticastInterface,
+		MulticastTTL:       config.MulticastTTL,
+		SyncUniverse:       config.SyncUniverse,
+	})
 
-	// torso
-	torso_sections := []Section{
-		sections["all"],
-		sections["torso"],
+	if err := handler.Setup(outputs); err != nil {
+		log.Fatal(err)
+	}
+	if err := handler.SetupDevices(pixelMap); err != nil {
+		log.Fatal(err)
 	}
-	*pixels = append(*pixels, *buildMammothSegment(5, 1, 400, 500, 90, torso_sections, torsoPixelType, RGB)...)
-	*pixels = append(*pixels, *buildMammothSegment(5, 21, 400, 400, 90, torso_sections, torsoPixelType, RGB)...)
-	*pixels = append(*pixels, *buildMammothSegment(5, 41, 400, 300, 90, torso_sections, torsoPixelType, RGB)...)
 
-	// head
-	head_sections := []Section{
-		sections["all"],
-		sections["head"],
+	// replaying a capture takes the place of the patterns entirely
+	if config.ReplayFile != "" {
+		if err := runReplay(config.ReplayFile, config.ReplayStep, config.ReplayLoop, handler); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	*pixels = append(*pixels, *buildMammothSegment(6, 1, 410, 550, 270, head_sections, headPixelType, RGB)...)
+	// closed on shutdown before the outputs
+	inputs := []io.Closer{}
 
-	// tusks
-	tusk_sections := []Section{
-		sections["all"],
-		sections["tusks"],
+	// external generators can send frames in over open pixel control for the opc pattern
+	if config.OPCServerAddress != "" {
+		opcServer, err := NewOPCServer(config.OPCServerAddress, opcFrames)
+		if err != nil {
+			log.Fatal(err)
+		}
+		inputs = append(inputs, opcServer)
 	}
-	*pixels = append(*pixels, *buildTuskSegment(31, 1, 350, 550, 225, tusk_sections, tuskPixelType, BRG)...)
-	*pixels = append(*pixels, *buildTuskSegment(32, 1, 460, 545, 315, tusk_sections, tuskPixelType, BRG)...)
 
-	pixelMap := PixelMap{
-		// pixels: buildPixelGrid(),
-		pixels: pixels,
-		// pixels: build2ChannelsOfPixels(),
+	// and consoles can take over universes over sACN
+	var receiver *SACNReceiver
+	if config.SACNInput || (config.ControlUniverse != 0 && config.ControlProtocol == PROTOCOL_SACN) {
+		receiver, err = NewSACNReceiver(SACNReceiverConfig{
+			Address:            config.SACNInputAddress,
+			Policy:             config.SACNInputMerge,
+			MulticastInterface: config.MulticastInterface,
+			IgnoreCID:          config.CID,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		inputs = append(inputs, receiver)
 	}
-
-	handler, err := NewSACNHandler()
-	if err != nil {
-		log.Fatal(err)
+	if config.SACNInput {
+		for _, output := range outputs {
+			if err := receiver.Listen(output.Universe, output.Priority); err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
 
-	universeNumbers := []uint16{1, 2, 3, 4, 5, 6, 31, 32}
-	handler.Setup(universeNumbers, config.ControllerAddress)
-
 	// verify universes are working
 	if err := handler.VerifyUniverses(); err != nil {
 		log.Printf("Warning: Failed to verify universes: %v", err)
 	}
 
+	// the handler owns the universe channels and closes them when it's closed
 	universes, errorTracker := handler.GetUniverses(), handler.GetErrorTracker()
-	for _, universe := range universes {
-		defer close(universe)
-	}
 
 	// now register patterns with controller
-	patterns := registerPatterns(&pixelMap)
+	patterns := registerPatterns(pixelMap)
 	if len(patterns) == 0 {
 		log.Fatal("no patterns registered")
 	}
@@ -125,17 +141,51 @@ func main() {
 		errorTracker,
 		config.TargetFramesPerSecond,
 		patterns["maskOnly"],
-		&pixelMap,
+		pixelMap,
 		*options,
 	)
+	controller.SetDevices(handler.GetDevices())
+	controller.SetUniverseSync(handler.SyncUniverses)
+	controller.SetChangesOnly(config.OutputChangesOnly)
+	if config.SACNInput {
+		controller.SetInputMerge(receiver.Merge)
+	}
+
+	shutdown := NewShutdown(config.ShutdownFade)
 
 	// create server config
 	serverConfig := &ServerConfig{
-		Options: *options,
+		Options:      *options,
+		Outputs:      handler,
+		Shutdown:     shutdown,
+		RecordingDir: config.RecordingDir,
+	}
+	if config.SACNInput {
+		serverConfig.Input = receiver
 	}
 
 	// create server
-	server := NewLEDServer(controller, &pixelMap, patterns, serverConfig)
+	server := NewLEDServer(controller, pixelMap, patterns, serverConfig)
+
+	// a lighting console can drive the controls like a fixture
+	if config.ControlUniverse != 0 {
+		var input DMXInput
+		switch config.ControlProtocol {
+		case PROTOCOL_SACN:
+			input = receiver
+		case PROTOCOL_ARTNET:
+			output, err := handler.output(PROTOCOL_ARTNET)
+			if err != nil {
+				log.Fatal(err)
+			}
+			input = output.(*ArtNetTransmitter)
+		default:
+			log.Fatalf("unknown control protocol %q", config.ControlProtocol)
+		}
+		if err := server.ListenForControl(input, config.ControlProtocol, config.ControlUniverse, config.ControlAddress); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// start the web server first
 	address := fmt.Sprintf("%v:%v", config.HostAddress, config.HostPort)
@@ -144,15 +194,20 @@ func main() {
 	}
 
 	// then start the controller
-	if err := controller.Start(&pixelMap); err != nil {
+	if err := controller.Start(pixelMap); err != nil {
 		log.Fatal(err)
 	}
+	if config.Record {
+		if _, err := controller.StartRecording(newCapturePath(config.RecordingDir)); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// wait for shutdown signal
+	// wait for a shutdown signal or request
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	fade := shutdown.Wait(sigChan)
 
 	// cleanup
-	controller.Stop()
+	shutdown.Run(fade, controller, handler, inputs)
 }
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// open pixel control is a tiny protocol over TCP: a channel, a command and a length, followed
+// by RGB triples. as a client we push frames to OPC servers such as Fadecandy bridges and
+// simulators. as a server we accept frames from external generators, which the opc pattern
+// then displays. channel 0 addresses every pixel in pixel map order, any other channel the
+// pixels of the universe with that number, in channel order.
+
+const (
+	OPCPort              = 7890
+	OPCHeaderLength      = 4
+	OPCSetPixelColors    = uint8(0x00)
+	OPCBroadcastChannel  = uint8(0)
+	OPCMaxData           = 0xFFFF
+	OPCConnectTimeout    = time.Second
+	OPCReconnectInterval = 2 * time.Second
+)
+
+// OPCConfig pushes frames to an OPC server. with universes set only their pixels are sent,
+// one universe after another, otherwise every pixel is sent in pixel map order.
+type OPCConfig struct {
+	Name      string   `json:"name"`
+	Address   string   `json:"address"` // host:port, the port defaults to 7890
+	Channel   uint8    `json:"channel,omitempty"`
+	Universes []uint16 `json:"universes,omitempty"`
+}
+
+// OPCTransmitter is the client side, keeping a connection open to each server
+type OPCTransmitter struct {
+	servers map[string]*OPCServerConnection
+	mu      sync.Mutex
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+type OPCServerConnection struct {
+	name        string
+	address     string
+	channel     uint8
+	conn        net.Conn
+	lastAttempt time.Time
+	frame       []byte
+	message     []byte // reused for every frame sent
+	slot        *FrameSlot
+}
+
+func NewOPCTransmitter() *OPCTransmitter {
+	return &OPCTransmitter{
+		servers: make(map[string]*OPCServerConnection),
+		done:    make(chan struct{}),
+	}
+}
+
+func (t *OPCTransmitter) Activate(name string, address string, channel uint8) (*FrameSlot, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, fmt.Sprint(OPCPort))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.servers[name]; exists {
+		return nil, fmt.Errorf("opc server %s already activated", name)
+	}
+
+	server := &OPCServerConnection{
+		name:    name,
+		address: address,
+		channel: channel,
+		slot:    NewFrameSlot(),
+	}
+
+	t.servers[name] = server
+
+	t.wg.Add(1)
+	go t.handleServer(server)
+
+	return server.slot, nil
+}
+
+func (t *OPCTransmitter) handleServer(server *OPCServerConnection) {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case <-server.slot.Ready():
+			// copied out first, so a slow server doesn't hold up publishing
+			if server.slot.Take(server.setFrame) {
+				t.sendFrame(server, server.frame)
+			}
+		case <-t.done:
+			if server.conn != nil {
+				server.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+func (server *OPCServerConnection) setFrame(data []byte) {
+	server.frame = append(server.frame[:0], data...)
+}
+
+// sends a frame, connecting first if needed. frames are dropped while the server is
+// unreachable, and reconnecting is only attempted every few seconds.
+func (t *OPCTransmitter) sendFrame(server *OPCServerConnection, data []byte) {
+	if server.conn == nil {
+		if time.Since(server.lastAttempt) < OPCReconnectInterval {
+			return
+		}
+		server.lastAttempt = time.Now()
+
+		conn, err := net.DialTimeout("tcp", server.address, OPCConnectTimeout)
+		if err != nil {
+			log.Printf("Error connecting to opc server %s at %s: %v", server.name, server.address, err)
+			return
+		}
+		log.Printf("Connected to opc server %s at %s", server.name, server.address)
+		server.conn = conn
+	}
+
+	if cap(server.message) < OPCHeaderLength+len(data) {
+		server.message = make([]byte, OPCHeaderLength+len(data))
+	}
+	message := server.message[:OPCHeaderLength+len(data)]
+	message[0] = server.channel
+	message[1] = OPCSetPixelColors
+	binary.BigEndian.PutUint16(message[2:4], uint16(len(data)))
+	copy(message[OPCHeaderLength:], data)
+
+	server.conn.SetWriteDeadline(time.Now().Add(OPCConnectTimeout))
+	if _, err := server.conn.Write(message); err != nil {
+		log.Printf("Error sending to opc server %s: %v", server.name, err)
+		server.conn.Close()
+		server.conn = nil
+	}
+}
+
+func (t *OPCTransmitter) Close() error {
+	close(t.done)
+	t.wg.Wait()
+
+	return nil
+}
+
+// OPCFrames holds the latest frame received on each channel
+type OPCFrames struct {
+	frames   map[uint8][]byte
+	received map[uint8]time.Time
+	channels []uint8 // every channel received on, in order
+	mu       sync.RWMutex
+}
+
+// frames received by the server, displayed by the opc pattern
+var opcFrames = NewOPCFrames()
+
+func NewOPCFrames() *OPCFrames {
+	return &OPCFrames{
+		frames:   make(map[uint8][]byte),
+		received: make(map[uint8]time.Time),
+	}
+}
+
+func (f *OPCFrames) set(channel uint8, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.frames[channel]; !exists {
+		f.channels = append(f.channels, channel)
+		slices.Sort(f.channels)
+	}
+	f.frames[channel] = data
+	f.received[channel] = time.Now()
+}
+
+// calls fn with every frame received within the hold time, the broadcast channel first so
+// universe channels are drawn over it
+func (f *OPCFrames) each(hold time.Duration, fn func(channel uint8, data []byte)) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, channel := range f.channels {
+		if time.Since(f.received[channel]) <= hold {
+			fn(channel, f.frames[channel])
+		}
+	}
+}
+
+// OPCServer accepts frames from external generators over TCP
+type OPCServer struct {
+	listener    net.Listener
+	frames      *OPCFrames
+	connections map[net.Conn]bool
+	mu          sync.Mutex
+	wg          sync.WaitGroup
+}
+
+func NewOPCServer(address string, frames *OPCFrames) (*OPCServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for opc clients on %s: %w", address, err)
+	}
+
+	s := &OPCServer{
+		listener:    listener,
+		frames:      frames,
+		connections: make(map[net.Conn]bool),
+	}
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	log.Printf("OPC server listening on %s", listener.Addr())
+	return s, nil
+}
+
+func (s *OPCServer) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error accepting opc client: %v", err)
+			continue
+		}
+
+		s.mu.Lock()
+		s.connections[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleClient(conn)
+	}
+}
+
+func (s *OPCServer) handleClient(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.connections, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	log.Printf("OPC client connected from %s", conn.RemoteAddr())
+
+	header := make([]byte, OPCHeaderLength)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Error reading from opc client %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		data := make([]byte, binary.BigEndian.Uint16(header[2:4]))
+		if _, err := io.ReadFull(conn, data); err != nil {
+			log.Printf("Error reading from opc client %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		// system exclusive and anything else we don't understand is skipped
+		if header[1] == OPCSetPixelColors {
+			s.frames.set(header[0], data)
+		}
+	}
+}
+
+func (s *OPCServer) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.connections {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// returns the pixels an OPC server is sent, in the order they're sent
+func (p *PixelMap) opcPixels(server OPCConfig) []*Pixel {
+	pixels := []*Pixel{}
+	if len(server.Universes) == 0 {
+		for i := range *p.pixels {
+			pixels = append(pixels, &(*p.pixels)[i])
+		}
+		return pixels
+	}
+
+	for _, universe := range server.Universes {
+		for _, index := range p.universeIndexes[universe] {
+			pixels = append(pixels, &(*p.pixels)[index])
+		}
+	}
+	return pixels
+}
+
+// counts the pixels an OPC server is sent, which can be done before the controller has put
+// the universes in channel order
+func (p *PixelMap) opcPixelCount(server OPCConfig) int {
+	if len(server.Universes) == 0 {
+		return len(*p.pixels)
+	}
+
+	universes := make(map[uint16]bool)
+	for _, universe := range server.Universes {
+		universes[universe] = true
+	}
+	deviceSegments := deviceSegments(p.ddp, p.wled)
+	count := 0
+	for _, segment := range p.segments {
+		if deviceSegments[segment.config.Name] {
+			continue
+		}
+		for _, pixel := range (*p.pixels)[segment.start : segment.start+segment.count] {
+			if universes[pixel.universe] {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// checks the OPC servers, whose names share the namespace of DDP devices
+func (p *PixelMap) checkOPC() error {
+	names := make(map[string]bool)
+	for _, device := range p.ddp {
+		names[device.Name] = true
+	}
+
+	for _, server := range p.opc {
+		if server.Name == "" || server.Address == "" {
+			return fmt.Errorf("opc servers need a name and an address")
+		}
+		if names[server.Name] {
+			return fmt.Errorf("opc server %s: name is already used by another output", server.Name)
+		}
+		names[server.Name] = true
+
+		if length := p.opcPixelCount(server) * 3; length > OPCMaxData {
+			return fmt.Errorf("opc server %s: %d bytes is more than a message can hold", server.Name, length)
+		}
+	}
+	return nil
+}
@@ -133,11 +133,12 @@ func (o *FloatOption) SetValue(value interface{}) error {
 
 // ColorCorrectionSection represents color correction settings for a specific section
 type ColorCorrectionSection struct {
-	ID    string     `json:"id"`
-	Label string     `json:"label"`
-	Red   TypedValue `json:"red"`
-	Green TypedValue `json:"green"`
-	Blue  TypedValue `json:"blue"`
+	ID     string     `json:"id"`
+	Label  string     `json:"label"`
+	Parent string     `json:"parent,omitempty"`
+	Red    TypedValue `json:"red"`
+	Green  TypedValue `json:"green"`
+	Blue   TypedValue `json:"blue"`
 }
 
 // ColorCorrectionOptions represents all color correction settings
@@ -379,6 +380,20 @@ func (o Options) MarshalJSON() ([]byte, error) {
 	return json.Marshal(registeredOptions)
 }
 
+// share returns options backed by the same registered option values, so changes made
+// through either are seen by both
+func (o *Options) share() Options {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return Options{
+		options:            o.options,
+		TransitionDuration: o.TransitionDuration,
+		TransitionEnabled:  o.TransitionEnabled,
+		ActiveMode:         o.ActiveMode,
+	}
+}
+
 // GetOption returns an option by ID
 func (o *Options) GetOption(id string) (Option, error) {
 	o.mu.RLock()
@@ -496,6 +511,37 @@ func DefaultOptions() *Options {
 		Max:   3.0, // Higher values make colors more muted
 	}
 
+	// dims heavier pixels (the mammoth's big pixels) so they look as bright as the small ones
+	options.options["weightCompensation"] = &BooleanOption{
+		ID:    "weightCompensation",
+		Label: "Pixel Weight Compensation",
+		Value: true,
+	}
+
+	// patterns that radiate from a point use the centroid of the layout unless it's turned
+	// off, in which case the origin is placed at a percentage of the layout's bounds
+	options.options["originAtCentroid"] = &BooleanOption{
+		ID:    "originAtCentroid",
+		Label: "Origin At Layout Centroid",
+		Value: true,
+	}
+
+	options.options["originX"] = &FloatOption{
+		ID:    "originX",
+		Label: "Origin X (% of width)",
+		Value: 50.0,
+		Min:   0.0,
+		Max:   100.0,
+	}
+
+	options.options["originY"] = &FloatOption{
+		ID:    "originY",
+		Label: "Origin Y (% of height)",
+		Value: 50.0,
+		Min:   0.0,
+		Max:   100.0,
+	}
+
 	// Note: Color correction options will be added later by AddColorCorrectionOptions
 	// based on the sections defined in main.go
 
@@ -533,28 +579,46 @@ func (options *Options) AddColorCorrectionOptions(sections map[string]Section) {
 
 	// Add each section to the color correction options
 	for _, section := range sections {
-		colorCorrectionOpt.Value.Sections[section.name] = ColorCorrectionSection{
-			ID:    section.name,
-			Label: section.label,
-			Red: TypedValue{
-				Value: 100.0,
-				Type:  TYPE_FLOAT,
-			},
-			Green: TypedValue{
-				Value: 100.0,
-				Type:  TYPE_FLOAT,
-			},
-			Blue: TypedValue{
-				Value: 100.0,
-				Type:  TYPE_FLOAT,
-			},
-		}
+		colorCorrectionOpt.Value.Sections[section.name] = defaultColorCorrectionSection(section)
 	}
 
 	// Register the color correction option
 	options.options["colorCorrection"] = colorCorrectionOpt
 }
 
+// AddColorCorrectionSection adds default color correction for a group created at runtime.
+// corrections multiply, so a nested section is corrected on top of its parents.
+func (o *Options) AddColorCorrectionSection(section Section) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if opt, ok := o.options["colorCorrection"].(*ColorCorrectionOption); ok {
+		if _, exists := opt.Value.Sections[section.name]; !exists {
+			opt.Value.Sections[section.name] = defaultColorCorrectionSection(section)
+		}
+	}
+}
+
+func defaultColorCorrectionSection(section Section) ColorCorrectionSection {
+	return ColorCorrectionSection{
+		ID:     section.name,
+		Label:  section.label,
+		Parent: section.parent,
+		Red: TypedValue{
+			Value: 100.0,
+			Type:  TYPE_FLOAT,
+		},
+		Green: TypedValue{
+			Value: 100.0,
+			Type:  TYPE_FLOAT,
+		},
+		Blue: TypedValue{
+			Value: 100.0,
+			Type:  TYPE_FLOAT,
+		},
+	}
+}
+
 // ResetToDefaults resets all options to their default values
 func (o *Options) ResetToDefaults() {
 	o.mu.Lock()
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Output sends universes of DMX data to controllers over one protocol. the pixel controller
+// only ever sees the slot each universe is published to, so it doesn't know or care which
+// protocol is on the wire.
+type Output interface {
+	// the name the protocol is selected by in configuration
+	Protocol() string
+
+	// starts sending the universe, returning the slot its frames are published to
+	Activate(universe uint16) (*FrameSlot, error)
+
+	// sets where the universe is sent. protocols without priorities ignore it.
+	SetDestination(universe uint16, address string, priority uint8) error
+
+	// sends the universe somewhere else as well
+	AddDestination(universe uint16, address string, priority uint8) error
+
+	Close() error
+}
+
+// MulticastOutput is implemented by outputs that can send universes to multicast groups
+type MulticastOutput interface {
+	AddMulticast(universe uint16, priority uint8) error
+}
+
+// SyncOutput is implemented by outputs that can hold universes until receivers are told to
+// show every universe of a frame at once
+type SyncOutput interface {
+	ActivateSynchronized(universe uint16, syncUniverse uint16) (*FrameSlot, error)
+
+	// sends everything written since the last sync, then tells receivers to show it
+	Sync() error
+}
+
+// OutputSettings configures the outputs themselves rather than any one universe
+type OutputSettings struct {
+	SourceName         string
+	CID                [16]byte
+	Discovery          bool // sends sACN universe discovery
+	MulticastInterface string
+	MulticastTTL       int
+	SyncUniverse       uint16 // 0 disables synchronization
+}
+
+const (
+	PROTOCOL_SACN   = "sacn"
+	PROTOCOL_ARTNET = "artnet"
+
+	OUTPUT_SOURCE_NAME = "GoLEDz"
+)
+
+// returns a random (version 4) UUID, used as the CID when none is configured so several
+// instances, or an instance receiving its own multicast, can tell each other apart
+func newCID() [16]byte {
+	var cid [16]byte
+	rand.Read(cid[:])
+	cid[6] = cid[6]&0x0F | 0x40
+	cid[8] = cid[8]&0x3F | 0x80
+	return cid
+}
+
+// parses a CID written as a UUID, e.g. 6ba7b810-9dad-11d1-80b4-00c04fd430c8
+func parseCID(value string) ([16]byte, error) {
+	var cid [16]byte
+	digits := strings.ReplaceAll(value, "-", "")
+	if len(digits) != 32 {
+		return cid, fmt.Errorf("invalid cid %q", value)
+	}
+	if _, err := hex.Decode(cid[:], []byte(digits)); err != nil {
+		return cid, fmt.Errorf("invalid cid %q: %w", value, err)
+	}
+	return cid, nil
+}
+
+// creates the output for each protocol, registered by name like patterns
+var outputFactories = map[string]func(settings OutputSettings) (Output, error){
+	PROTOCOL_SACN: func(settings OutputSettings) (Output, error) {
+		return NewTransmitter(TransmitterConfig{
+			CID:                settings.CID,
+			SourceName:         settings.SourceName,
+			Priority:           100,
+			MulticastInterface: settings.MulticastInterface,
+			MulticastTTL:       settings.MulticastTTL,
+			Discovery:          settings.Discovery,
+		})
+	},
+	PROTOCOL_ARTNET: func(settings OutputSettings) (Output, error) {
+		return NewArtNetTransmitter(settings.SourceName)
+	},
+}
+
+// UniverseOutput is where and how one universe is sent
+type UniverseOutput struct {
+	Universe     uint16   `json:"universe"`
+	Protocol     string   `json:"protocol"`
+	Destinations []string `json:"destinations"`
+	Priority     uint8    `json:"priority"`
+	Multicast    bool     `json:"multicast,omitempty"`
+	Sync         bool     `json:"sync,omitempty"`
+}
+
+// OutputsInfo describes every universe's output, along with any Art-Net nodes that have
+// answered a poll
+type OutputsInfo struct {
+	Universes   []UniverseOutput `json:"universes"`
+	DDPDevices  []DDPDeviceInfo  `json:"ddpDevices,omitempty"`
+	OPCServers  []OPCConfig      `json:"opcServers,omitempty"`
+	WLEDNodes   []WLEDNodeInfo   `json:"wledNodes,omitempty"`
+	ArtNetNodes []ArtNetNode     `json:"artnetNodes,omitempty"`
+}
+
+// OutputHandler owns an output per protocol in use and routes each universe to its protocol
+type OutputHandler struct {
+	outputs      map[string]Output
+	universes    map[uint16]*FrameSlot
+	routes       map[uint16]UniverseOutput
+	settings     OutputSettings
+	ddp          *DDPTransmitter
+	devices      map[string]*FrameSlot
+	ddpDevices   []DDPDeviceInfo
+	opc          *OPCTransmitter
+	opcServers   []OPCConfig
+	wled         *WLEDTransmitter
+	wledNodes    []WLEDNodeInfo
+	errorTracker *ErrorTracker
+}
+
+func NewOutputHandler(settings OutputSettings) *OutputHandler {
+	return &OutputHandler{
+		settings:     settings,
+		outputs:      make(map[string]Output),
+		universes:    make(map[uint16]*FrameSlot),
+		routes:       make(map[uint16]UniverseOutput),
+		devices:      make(map[string]*FrameSlot),
+		errorTracker: NewErrorTracker(5*time.Minute, 50),
+	}
+}
+
+// returns the output for a protocol, starting it the first time it's needed
+func (oh *OutputHandler) output(protocol string) (Output, error) {
+	if output, exists := oh.outputs[protocol]; exists {
+		return output, nil
+	}
+
+	factory, exists := outputFactories[protocol]
+	if !exists {
+		return nil, fmt.Errorf("unknown output protocol %q", protocol)
+	}
+	output, err := factory(oh.settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s output: %w", protocol, err)
+	}
+	oh.outputs[protocol] = output
+	return output, nil
+}
+
+// activates a universe, held for the sync universe when it's synchronized
+func (oh *OutputHandler) activate(output Output, universe UniverseOutput) (*FrameSlot, error) {
+	if !universe.Sync {
+		return output.Activate(universe.Universe)
+	}
+
+	synced, ok := output.(SyncOutput)
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't support synchronization", universe.Protocol)
+	}
+	if oh.settings.SyncUniverse == 0 {
+		return nil, fmt.Errorf("synchronization needs a sync universe")
+	}
+	if oh.settings.SyncUniverse == universe.Universe {
+		return nil, fmt.Errorf("the sync universe can't also carry data")
+	}
+	return synced.ActivateSynchronized(universe.Universe, oh.settings.SyncUniverse)
+}
+
+func (oh *OutputHandler) Setup(universes []UniverseOutput) error {
+	// activate each universe separately
+	for _, universe := range universes {
+		output, err := oh.output(universe.Protocol)
+		if err != nil {
+			return fmt.Errorf("universe %d: %w", universe.Universe, err)
+		}
+
+		ch, err := oh.activate(output, universe)
+		if err != nil {
+			return fmt.Errorf("failed to activate universe %d: %w", universe.Universe, err)
+		}
+
+		oh.universes[universe.Universe] = ch
+		oh.routes[universe.Universe] = universe
+
+		// set destinations for each universe
+		for i, address := range universe.Destinations {
+			if i == 0 {
+				err = output.SetDestination(universe.Universe, address, universe.Priority)
+			} else {
+				err = output.AddDestination(universe.Universe, address, universe.Priority)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to set destination for universe %d: %w", universe.Universe, err)
+			}
+		}
+
+		if universe.Multicast {
+			multicast, ok := output.(MulticastOutput)
+			if !ok {
+				return fmt.Errorf("universe %d: %s doesn't support multicast", universe.Universe, universe.Protocol)
+			}
+			if err := multicast.AddMulticast(universe.Universe, universe.Priority); err != nil {
+				return fmt.Errorf("failed to add multicast for universe %d: %w", universe.Universe, err)
+			}
+		}
+
+		// initialize with zero data, effectively turning off the lights
+		ch.Publish(make([]byte, 512))
+		if err := oh.SyncUniverses(); err != nil {
+			return err
+		}
+
+		destinations := slices.Clone(universe.Destinations)
+		if universe.Multicast {
+			destinations = append(destinations, multicastAddress(universe.Universe).IP.String())
+		}
+		log.Printf("Universe %d sending %s to %s", universe.Universe, universe.Protocol, strings.Join(destinations, ", "))
+		if universe.Sync {
+			log.Printf("Universe %d synchronized by universe %d", universe.Universe, oh.settings.SyncUniverse)
+		}
+
+		// give the controller time to process each universe
+		time.Sleep(25 * time.Millisecond)
+	}
+
+	return nil
+}
+
+// SetupDevices activates the DDP devices, OPC servers and WLED nodes declared in the layout
+func (oh *OutputHandler) SetupDevices(pixelMap *PixelMap) error {
+	if err := oh.setupDDP(pixelMap); err != nil {
+		return err
+	}
+	if err := oh.setupOPC(pixelMap); err != nil {
+		return err
+	}
+	return oh.setupWLED(pixelMap)
+}
+
+func (oh *OutputHandler) setupDDP(pixelMap *PixelMap) error {
+	if len(pixelMap.ddp) == 0 {
+		return nil
+	}
+
+	if oh.ddp == nil {
+		transmitter, err := NewDDPTransmitter()
+		if err != nil {
+			return fmt.Errorf("failed to create ddp output: %w", err)
+		}
+		oh.ddp = transmitter
+	}
+
+	for _, device := range pixelMap.ddp {
+		ch, err := oh.ddp.Activate(device.Name, device.Address)
+		if err != nil {
+			return fmt.Errorf("failed to activate ddp device %s: %w", device.Name, err)
+		}
+		oh.devices[device.Name] = ch
+
+		info := pixelMap.ddpDeviceInfo(device)
+		oh.ddpDevices = append(oh.ddpDevices, info)
+
+		last := info.Segments[len(info.Segments)-1]
+		log.Printf("DDP device %s sending %d bytes to %s", device.Name, last.Offset+last.Length, device.Address)
+	}
+
+	return nil
+}
+
+func (oh *OutputHandler) setupOPC(pixelMap *PixelMap) error {
+	if len(pixelMap.opc) == 0 {
+		return nil
+	}
+
+	if oh.opc == nil {
+		oh.opc = NewOPCTransmitter()
+	}
+
+	for _, server := range pixelMap.opc {
+		ch, err := oh.opc.Activate(server.Name, server.Address, server.Channel)
+		if err != nil {
+			return fmt.Errorf("failed to activate opc server %s: %w", server.Name, err)
+		}
+		oh.devices[server.Name] = ch
+		oh.opcServers = append(oh.opcServers, server)
+
+		log.Printf("OPC server %s at %s sending %d pixels on channel %d", server.Name, server.Address, pixelMap.opcPixelCount(server), server.Channel)
+	}
+
+	return nil
+}
+
+func (oh *OutputHandler) setupWLED(pixelMap *PixelMap) error {
+	if len(pixelMap.wled) == 0 {
+		return nil
+	}
+
+	if oh.wled == nil {
+		transmitter, err := NewWLEDTransmitter()
+		if err != nil {
+			return fmt.Errorf("failed to create wled output: %w", err)
+		}
+		oh.wled = transmitter
+	}
+
+	for _, node := range pixelMap.wled {
+		ch, err := oh.wled.Activate(node.Name, node.Address, node.timeout())
+		if err != nil {
+			return fmt.Errorf("failed to activate wled node %s: %w", node.Name, err)
+		}
+		oh.devices[node.Name] = ch
+
+		info := pixelMap.wledNodeInfo(node)
+		oh.wledNodes = append(oh.wledNodes, info)
+
+		log.Printf("WLED node %s sending %d pixels to %s over %s", node.Name, info.Pixels, node.Address, info.Protocol)
+	}
+
+	return nil
+}
+
+func (oh *OutputHandler) GetDevices() map[string]*FrameSlot {
+	return oh.devices
+}
+
+func (oh *OutputHandler) GetUniverses() map[uint16]*FrameSlot {
+	return oh.universes
+}
+
+func (oh *OutputHandler) GetErrorTracker() *ErrorTracker {
+	return oh.errorTracker
+}
+
+// Info describes where each universe is sent
+func (oh *OutputHandler) Info() OutputsInfo {
+	info := OutputsInfo{Universes: make([]UniverseOutput, 0, len(oh.routes))}
+	for _, route := range oh.routes {
+		info.Universes = append(info.Universes, route)
+	}
+	sort.Slice(info.Universes, func(i, j int) bool { return info.Universes[i].Universe < info.Universes[j].Universe })
+	info.DDPDevices = oh.ddpDevices
+	info.OPCServers = oh.opcServers
+	info.WLEDNodes = oh.wledNodes
+
+	if output, exists := oh.outputs[PROTOCOL_ARTNET]; exists {
+		info.ArtNetNodes = output.(*ArtNetTransmitter).Nodes()
+	}
+	return info
+}
+
+// Metrics counts the frames published to each universe and device, and how many of them
+// were dropped or late
+func (oh *OutputHandler) Metrics() OutputMetrics {
+	metrics := OutputMetrics{Universes: make([]UniverseMetrics, 0, len(oh.universes))}
+	for universe, slot := range oh.universes {
+		metrics.Universes = append(metrics.Universes, UniverseMetrics{Universe: universe, FrameMetrics: slot.Metrics()})
+	}
+	sort.Slice(metrics.Universes, func(i, j int) bool { return metrics.Universes[i].Universe < metrics.Universes[j].Universe })
+
+	for name, slot := range oh.devices {
+		metrics.Devices = append(metrics.Devices, DeviceMetrics{Name: name, FrameMetrics: slot.Metrics()})
+	}
+	sort.Slice(metrics.Devices, func(i, j int) bool { return metrics.Devices[i].Name < metrics.Devices[j].Name })
+	return metrics
+}
+
+func (oh *OutputHandler) Close() error {
+	var firstErr error
+	for protocol, output := range oh.outputs {
+		if err := output.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close %s output: %w", protocol, err)
+		}
+	}
+	if oh.ddp != nil {
+		if err := oh.ddp.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close ddp output: %w", err)
+		}
+	}
+	if oh.opc != nil {
+		if err := oh.opc.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close opc output: %w", err)
+		}
+	}
+	if oh.wled != nil {
+		if err := oh.wled.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close wled output: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// verify all universes are active
+func (oh *OutputHandler) VerifyUniverses() error {
+	// send blank pixels to each universe to verify it's working
+	for universeNumber, ch := range oh.universes {
+		testData := make([]byte, 512)
+		// create a unique pattern for this universe
+		for i := range testData {
+			testData[i] = byte(universeNumber & 0xFF)
+		}
+
+		ch.Publish(testData)
+		if err := oh.SyncUniverses(); err != nil {
+			return err
+		}
+
+		time.Sleep(25 * time.Millisecond)
+	}
+
+	return nil
+}
+
+// SyncUniverses tells receivers of synchronized universes to show the frame that was just
+// written. the controller calls it after writing every universe.
+func (oh *OutputHandler) SyncUniverses() error {
+	for protocol, output := range oh.outputs {
+		if synced, ok := output.(SyncOutput); ok {
+			if err := synced.Sync(); err != nil {
+				return fmt.Errorf("failed to sync %s universes: %w", protocol, err)
+			}
+		}
+	}
+	return nil
+}
@@ -6,9 +6,6 @@ const MAX_HUE_VALUE = 360
 const MAX_DEGREES = 360
 const MAX_SATURATION = 1.0
 
-// abitrary for now; we'll calculate this later
-const MAX_X_POSITION = 600
-
 // TODO: return and handle any errors encountered in updating patterns
 
 type PatternUpdateRequest interface {
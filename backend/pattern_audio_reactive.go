@@ -96,7 +96,7 @@ func (p *AudioReactivePattern) applyPulseEffect(audioLevel float64) {
 		// Apply color mask if available
 		if p.GetColorMask() != nil {
 			pixel := (*p.pixelMap.pixels)[i]
-			maskColor := p.GetColorMask().GetColorAt(Point{pixel.x, pixel.y})
+			maskColor := maskColorAt(p.GetColorMask(), &pixel)
 
 			// Blend between base color and mask color based on audio level
 			(*p.pixelMap.pixels)[i].color = Color{
@@ -139,7 +139,7 @@ func (p *AudioReactivePattern) applyWaveEffect(audioLevel float64) {
 
 		// Apply color mask if available
 		if p.GetColorMask() != nil {
-			maskColor := p.GetColorMask().GetColorAt(Point{pixel.x, pixel.y})
+			maskColor := maskColorAt(p.GetColorMask(), &pixel)
 
 			// Blend between base color and mask color based on wave effect
 			(*p.pixelMap.pixels)[i].color = Color{
@@ -179,7 +179,7 @@ func (p *AudioReactivePattern) applySparkleEffect(audioLevel float64) {
 		// Apply color mask if available
 		if p.GetColorMask() != nil {
 			pixel := (*p.pixelMap.pixels)[idx]
-			maskColor := p.GetColorMask().GetColorAt(Point{pixel.x, pixel.y})
+			maskColor := maskColorAt(p.GetColorMask(), &pixel)
 			(*p.pixelMap.pixels)[idx].color = maskColor
 		} else {
 			(*p.pixelMap.pixels)[idx].color = accentColor
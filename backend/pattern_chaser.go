@@ -37,35 +37,39 @@ type ChaserParameters struct {
 
 func (p *ChaserPattern) Update() {
 	speed := p.Parameters.Speed.Value
-	size := p.Parameters.Size.Value
-	spacing := p.Parameters.Spacing.Value
 	reversed := p.Parameters.Reversed.Value
 
-	width := uint16(size + spacing)
+	// chase along each strip in wiring order
+	renderStrips(p.pixelMap, p)
+
+	if reversed {
+		// ensures that this value will not dip below 0
+		p.currentPosition = MAX_PIXEL_LENGTH + p.currentPosition - speed
+	} else {
+		p.currentPosition = p.currentPosition + speed
+	}
+	p.currentPosition = math.Mod(p.currentPosition, MAX_PIXEL_LENGTH)
+}
+
+func (p *ChaserPattern) RenderStrip(strip *Strip) {
+	size := p.Parameters.Size.Value
+	width := size + p.Parameters.Spacing.Value
+	offset := int(p.currentPosition)
 
-	for i, pixel := range *p.pixelMap.pixels {
-		point := Point{pixel.x, pixel.y}
-		chaserPos := pixel.channelPosition + uint16(p.currentPosition)
+	for position := 0; position < strip.Len(); position++ {
+		pixel := strip.At(position)
 
-		if width > 0 && (chaserPos%width < uint16(size)) {
+		if width > 0 && ((position+offset)%width < size) {
 			if p.GetColorMask() != nil {
-				(*p.pixelMap.pixels)[i].color = p.GetColorMask().GetColorAt(point)
+				pixel.color = maskColorAt(p.GetColorMask(), pixel)
 			} else {
 				// Default white if no color mask is set
-				(*p.pixelMap.pixels)[i].color = Color{255, 255, 255, 0}
+				pixel.color = Color{255, 255, 255, 0}
 			}
 		} else {
-			(*p.pixelMap.pixels)[i].color = Color{0, 0, 0, 0}
+			pixel.color = Color{0, 0, 0, 0}
 		}
 	}
-
-	if reversed {
-		// ensures that this value will not dip below 0
-		p.currentPosition = MAX_PIXEL_LENGTH + p.currentPosition - speed
-	} else {
-		p.currentPosition = p.currentPosition + speed
-	}
-	p.currentPosition = math.Mod(p.currentPosition, MAX_PIXEL_LENGTH)
 }
 
 func (p *ChaserPattern) GetName() string {
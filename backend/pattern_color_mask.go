@@ -1,5 +1,7 @@
 package main
 
+import "math"
+
 // defines the interface for patterns that provide color values
 type ColorMaskPattern interface {
 	Pattern
@@ -21,3 +23,52 @@ type ColorMaskParameters struct {
 	Speed    FloatParameter   `json:"speed"`
 	Reversed BooleanParameter `json:"reversed"`
 }
+
+// pixels are sampled at their center only unless they're at least this size, and this many
+// times the layout's typical pixel size (see computeSampling)
+const (
+	MIN_SAMPLED_PIXEL_SIZE    = 4.0
+	SAMPLED_PIXEL_SIZE_FACTOR = 2.0
+)
+
+// offsets of the ring of samples taken around a big pixel's center, as fractions of its size
+var pixelSampleOffsets = func() [][2]float64 {
+	offsets := [][2]float64{}
+	for i := 0; i < 6; i++ {
+		angle := float64(i) * math.Pi / 3
+		offsets = append(offsets, [2]float64{math.Cos(angle) / 3, math.Sin(angle) / 3})
+	}
+	return offsets
+}()
+
+// returns the mask's color for a pixel. a big pixel shows the average of the mask over its
+// footprint rather than whatever happens to be at its center, so fine detail in the mask
+// doesn't alias where the pixels are sparse.
+func maskColorAt(mask ColorMaskPattern, pixel *Pixel) Color {
+	center := Point{pixel.x, pixel.y, pixel.z}
+	if !pixel.sampled {
+		return mask.GetColorAt(center)
+	}
+
+	color := mask.GetColorAt(center)
+	r, g, b, w := float64(color.R), float64(color.G), float64(color.B), float64(color.W)
+	for _, offset := range pixelSampleOffsets {
+		sample := mask.GetColorAt(Point{
+			X: pixel.x + int16(math.Round(offset[0]*pixel.size)),
+			Y: pixel.y + int16(math.Round(offset[1]*pixel.size)),
+			Z: pixel.z,
+		})
+		r += float64(sample.R)
+		g += float64(sample.G)
+		b += float64(sample.B)
+		w += float64(sample.W)
+	}
+
+	count := float64(len(pixelSampleOffsets) + 1)
+	return Color{
+		R: colorPigment(r / count),
+		G: colorPigment(g / count),
+		B: colorPigment(b / count),
+		W: colorPigment(w / count),
+	}
+}
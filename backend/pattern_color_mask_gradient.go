@@ -7,6 +7,7 @@ import (
 
 type GradientColorMask struct {
 	BasePattern
+	pixelMap     *PixelMap
 	Parameters   GradientParameters `json:"parameters"`
 	Label        string             `json:"label,omitempty"`
 	currentAngle float64
@@ -18,7 +19,7 @@ func (p *GradientColorMask) GetColorAt(point Point) Color {
 	blendSize := p.Parameters.BlendSize.Value
 
 	// Pass the blend size to the modified GetColorAtPoint function
-	calculatedColor := GetColorAtPointWithBlendSize(point, color1, color2, p.currentAngle, blendSize)
+	calculatedColor := GetColorAtPointWithBlendSize(point, p.pixelMap.bounds(), color1, color2, p.currentAngle, blendSize)
 
 	// The GetColorAtPoint function now handles saturation boosting
 	return Color{
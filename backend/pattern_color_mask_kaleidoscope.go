@@ -8,6 +8,7 @@ import (
 
 type KaleidoscopeColorMask struct {
 	BasePattern
+	pixelMap     *PixelMap
 	Parameters   KaleidoscopeParameters `json:"parameters"`
 	Label        string                 `json:"label,omitempty"`
 	startTime    time.Time
@@ -39,13 +40,10 @@ func (p *KaleidoscopeColorMask) GetColorAt(point Point) Color {
 	distortion := p.Parameters.Distortion.Value
 	colorBlendMode := p.Parameters.ColorBlendMode.Value
 
-	// center coordinates
-	centerX := (MAX_X + MIN_X) / 2
-	centerY := (MAX_Y + MIN_Y) / 2
-
-	// translate to center
-	x := float64(point.X) - float64(centerX)
-	y := float64(point.Y) - float64(centerY)
+	// translate to the layout's origin
+	origin := p.pixelMap.origin()
+	x := float64(point.X) - float64(origin.X)
+	y := float64(point.Y) - float64(origin.Y)
 
 	// apply rotation
 	rotatedX := x*math.Cos(p.currentAngle) - y*math.Sin(p.currentAngle)
@@ -72,7 +70,7 @@ func (p *KaleidoscopeColorMask) GetColorAt(point Point) Color {
 	distortedR := r + distortion*math.Sin(segmentPosition*5)
 
 	// normalize coordinates for color mapping
-	normalizedR := math.Min(distortedR/(MAX_X-MIN_X), 1.0)
+	normalizedR := math.Min(distortedR/max(p.pixelMap.bounds().Width(), 1), 1.0)
 	normalizedTheta := segmentPosition / segmentAngle
 
 	// determine color based on blend mode
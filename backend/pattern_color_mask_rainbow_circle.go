@@ -9,13 +9,14 @@ import (
 
 type RainbowCircleMask struct {
 	BasePattern
+	pixelMap   *PixelMap
 	Parameters RainbowCircleParameters `json:"parameters"`
 	Label      string                  `json:"label,omitempty"`
 	currentHue float64
 }
 
 func (p *RainbowCircleMask) GetColorAt(point Point) Color {
-	distance := math.Sqrt(math.Pow(float64(CENTER_X-point.X), 2) + math.Pow(float64(CENTER_Y-point.Y), 2))
+	distance := distanceBetweenPoints(point, p.pixelMap.origin())
 	hueVal := math.Mod(p.currentHue+distance, MAX_HUE_VALUE)
 	c := colorful.Hsv(hueVal, 1.0, 1.0)
 	return Color{
@@ -9,13 +9,14 @@ import (
 
 type RainbowPinwheelMask struct {
 	BasePattern
+	pixelMap   *PixelMap
 	Parameters RainbowPinwheelParameters `json:"parameters"`
 	Label      string                    `json:"label,omitempty"`
 	currentHue float64
 }
 
 func (p *RainbowPinwheelMask) GetColorAt(point Point) Color {
-	rotationDegrees := calculateAngle(point, Point{CENTER_X, CENTER_Y})
+	rotationDegrees := calculateAngle(point, p.pixelMap.origin())
 	hueVal := math.Mod(p.currentHue+rotationDegrees, MAX_HUE_VALUE)
 	c := colorful.Hsv(hueVal, 1.0, 1.0)
 	return Color{
@@ -8,6 +8,7 @@ import (
 
 type WaveColorMask struct {
 	BasePattern
+	pixelMap   *PixelMap
 	Parameters WaveParameters `json:"parameters"`
 	Label      string         `json:"label,omitempty"`
 	startTime  time.Time
@@ -42,8 +43,7 @@ func (p *WaveColorMask) GetColorAt(point Point) Color {
 	amplitude := p.Parameters.Amplitude.Value
 
 	// normalize coordinates
-	nx := float64(point.X-MIN_X) / float64(MAX_X-MIN_X)
-	ny := float64(point.Y-MIN_Y) / float64(MAX_Y-MIN_Y)
+	nx, ny, _ := p.pixelMap.normalize(point)
 
 	// calculate wave value based on interference mode
 	var waveValue float64
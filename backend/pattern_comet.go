@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+type CometPattern struct {
+	BasePattern
+	pixelMap   *PixelMap
+	positions  map[int]float64 // where the first comet is along each strip, by strip index
+	Parameters CometParameters `json:"parameters"`
+	Label      string          `json:"label,omitempty"`
+}
+
+func (p *CometPattern) UpdateParameters(parameters AdjustableParameters) error {
+	newParams, ok := parameters.(CometParameters)
+	if !ok {
+		err := fmt.Sprintf("Could not cast updated parameters for %v pattern", p.GetName())
+		return errors.New(err)
+	}
+
+	p.Parameters.Speed.Update(newParams.Speed.Value)
+	p.Parameters.TailLength.Update(newParams.TailLength.Value)
+	p.Parameters.Count.Update(newParams.Count.Value)
+	p.Parameters.Reversed.Update(newParams.Reversed.Value)
+	return nil
+}
+
+type CometParameters struct {
+	Speed      FloatParameter   `json:"speed"`
+	TailLength IntParameter     `json:"tailLength"`
+	Count      IntParameter     `json:"count"`
+	Reversed   BooleanParameter `json:"reversed"`
+}
+
+func (p *CometPattern) Update() {
+	if p.positions == nil {
+		p.positions = make(map[int]float64)
+	}
+
+	renderStrips(p.pixelMap, p)
+}
+
+// each strip gets its own evenly spaced comets, with tails fading out behind the heads
+func (p *CometPattern) RenderStrip(strip *Strip) {
+	length := strip.Len()
+	if length == 0 {
+		return
+	}
+
+	tailLength := float64(p.Parameters.TailLength.Value)
+	count := p.Parameters.Count.Value
+	spacing := float64(length) / float64(count)
+	reversed := p.Parameters.Reversed.Value
+	current := p.positions[strip.index]
+
+	for position := 0; position < length; position++ {
+		pixel := strip.At(position)
+
+		brightness := 0.0
+		for comet := 0; comet < count; comet++ {
+			head := math.Mod(current+float64(comet)*spacing, float64(length))
+
+			// distance behind the head, in the direction of travel
+			behind := head - float64(position)
+			if reversed {
+				behind = -behind
+			}
+			if behind < 0 {
+				behind += float64(length)
+			}
+
+			if behind < tailLength {
+				fade := 1.0 - behind/tailLength
+				brightness = math.Max(brightness, fade*fade)
+			}
+		}
+
+		if brightness == 0 {
+			pixel.color = Color{0, 0, 0, 0}
+			continue
+		}
+
+		color := Color{255, 255, 255, 0}
+		if p.GetColorMask() != nil {
+			color = maskColorAt(p.GetColorMask(), pixel)
+		}
+		pixel.color = Color{
+			R: colorPigment(float64(color.R) * brightness),
+			G: colorPigment(float64(color.G) * brightness),
+			B: colorPigment(float64(color.B) * brightness),
+			W: 0,
+		}
+	}
+
+	// each strip wraps at its own length, so the comets pass every pixel of it
+	if reversed {
+		current -= p.Parameters.Speed.Value
+	} else {
+		current += p.Parameters.Speed.Value
+	}
+	p.positions[strip.index] = wrapPosition(current, length)
+}
+
+func (p *CometPattern) GetName() string {
+	return "comet"
+}
+
+type CometUpdateRequest struct {
+	Parameters CometParameters `json:"parameters"`
+}
+
+func (r *CometUpdateRequest) GetParameters() AdjustableParameters {
+	return r.Parameters
+}
+
+func (p *CometPattern) GetPatternUpdateRequest() PatternUpdateRequest {
+	return &CometUpdateRequest{
+		Parameters: p.Parameters,
+	}
+}
+
+func (p *CometPattern) TransitionFrom(source Pattern, progress float64) {
+	DefaultTransitionFromPattern(p, source, progress, p.pixelMap)
+}
@@ -129,7 +129,7 @@ func (p *FirePattern) simulateFire(cooling, sparking, windDirection, windStrengt
 	// Create a mapping from pixel coordinates to heat map indices
 	pixelToHeatIndex := make(map[Point]int)
 	for i, pixel := range *p.pixelMap.pixels {
-		pixelToHeatIndex[Point{pixel.x, pixel.y}] = i
+		pixelToHeatIndex[Point{pixel.x, pixel.y, pixel.z}] = i
 	}
 
 	// Heat rises - for each pixel, find pixels above it and transfer heat
@@ -143,7 +143,7 @@ func (p *FirePattern) simulateFire(cooling, sparking, windDirection, windStrengt
 			aboveX := pixel.x + windOffset
 
 			// Find the pixel at this position
-			abovePoint := Point{aboveX, aboveY}
+			abovePoint := Point{aboveX, aboveY, pixel.z}
 			if aboveIndex, exists := pixelToHeatIndex[abovePoint]; exists {
 				// Transfer more heat upward (50% instead of 40%)
 				heatTransfer := p.heatMap[i] * 0.5
@@ -205,7 +205,7 @@ func (p *FirePattern) mapHeatToColors(colorScheme int) {
 		// Apply color mask if available
 		if p.GetColorMask() != nil {
 			pixel := (*p.pixelMap.pixels)[i]
-			maskColor := p.GetColorMask().GetColorAt(Point{pixel.x, pixel.y})
+			maskColor := maskColorAt(p.GetColorMask(), &pixel)
 
 			// Blend with mask color based on heat
 			color = Color{
@@ -46,7 +46,7 @@ func (p *GradientPattern) Update() {
 	blendSize := p.Parameters.BlendSize.Value
 
 	for i, pixel := range *p.pixelMap.pixels {
-		calculatedColor := GetColorAtPointWithBlendSize(Point{pixel.x, pixel.y}, color1, color2, p.currentAngle, blendSize)
+		calculatedColor := GetColorAtPointWithBlendSize(Point{pixel.x, pixel.y, pixel.z}, p.pixelMap.bounds(), color1, color2, p.currentAngle, blendSize)
 		(*p.pixelMap.pixels)[i].color = Color{
 			R: calculatedColor.R,
 			G: calculatedColor.G,
@@ -80,7 +80,7 @@ func (p *GradientPattern) GetPatternUpdateRequest() PatternUpdateRequest {
 	}
 }
 
-func GetColorAtPoint(p Point, color1 Color, color2 Color, angleDegrees float64) Color {
+func GetColorAtPoint(p Point, bounds Bounds, color1 Color, color2 Color, angleDegrees float64) Color {
 	angleRad := degreesToRadians(angleDegrees)
 
 	// create a unit vector in the direction of the gradient
@@ -90,26 +90,26 @@ func GetColorAtPoint(p Point, color1 Color, color2 Color, angleDegrees float64)
 	}
 
 	// calculate the maximum possible projection based on the angle
-	// this finds the projection of the corner point (MAX_X,MAX_Y) onto the gradient direction
+	// this finds the projection of the corners of the layout's bounds onto the gradient direction
 	maxProjection := math.Max(
 		math.Max(
-			projectPoint(Point{MIN_X, MIN_Y}, gradientDir),
-			projectPoint(Point{MAX_X, MIN_Y}, gradientDir),
+			projectPoint(Point{X: bounds.Min.X, Y: bounds.Min.Y}, gradientDir),
+			projectPoint(Point{X: bounds.Max.X, Y: bounds.Min.Y}, gradientDir),
 		),
 		math.Max(
-			projectPoint(Point{MIN_X, MAX_Y}, gradientDir),
-			projectPoint(Point{MAX_X, MAX_Y}, gradientDir),
+			projectPoint(Point{X: bounds.Min.X, Y: bounds.Max.Y}, gradientDir),
+			projectPoint(Point{X: bounds.Max.X, Y: bounds.Max.Y}, gradientDir),
 		),
 	)
 
 	minProjection := math.Min(
 		math.Min(
-			projectPoint(Point{MIN_X, MIN_Y}, gradientDir),
-			projectPoint(Point{MAX_X, MIN_Y}, gradientDir),
+			projectPoint(Point{X: bounds.Min.X, Y: bounds.Min.Y}, gradientDir),
+			projectPoint(Point{X: bounds.Max.X, Y: bounds.Min.Y}, gradientDir),
 		),
 		math.Min(
-			projectPoint(Point{MIN_X, MAX_Y}, gradientDir),
-			projectPoint(Point{MAX_X, MAX_Y}, gradientDir),
+			projectPoint(Point{X: bounds.Min.X, Y: bounds.Max.Y}, gradientDir),
+			projectPoint(Point{X: bounds.Max.X, Y: bounds.Max.Y}, gradientDir),
 		),
 	)
 
@@ -117,7 +117,11 @@ func GetColorAtPoint(p Point, color1 Color, color2 Color, angleDegrees float64)
 	projection := projectPoint(p, gradientDir)
 
 	// normalize the projection to get a value between 0 and 1
-	t := (projection - minProjection) / (maxProjection - minProjection)
+	// a layout with no extent along the gradient sits entirely at the start of it
+	t := 0.0
+	if maxProjection > minProjection {
+		t = (projection - minProjection) / (maxProjection - minProjection)
+	}
 	t = math.Max(0, math.Min(1, t))
 
 	// convert RGB to HSV for better interpolation
@@ -177,7 +181,7 @@ func (p *GradientPattern) TransitionFrom(source Pattern, progress float64) {
 	DefaultTransitionFromPattern(p, source, progress, p.pixelMap)
 }
 
-func GetColorAtPointWithBlendSize(p Point, color1 Color, color2 Color, angleDegrees float64, blendSize float64) Color {
+func GetColorAtPointWithBlendSize(p Point, bounds Bounds, color1 Color, color2 Color, angleDegrees float64, blendSize float64) Color {
 	angleRad := degreesToRadians(angleDegrees)
 
 	// create a unit vector in the direction of the gradient
@@ -189,23 +193,23 @@ func GetColorAtPointWithBlendSize(p Point, color1 Color, color2 Color, angleDegr
 	// calculate the maximum possible projection based on the angle
 	maxProjection := math.Max(
 		math.Max(
-			projectPoint(Point{MIN_X, MIN_Y}, gradientDir),
-			projectPoint(Point{MAX_X, MIN_Y}, gradientDir),
+			projectPoint(Point{X: bounds.Min.X, Y: bounds.Min.Y}, gradientDir),
+			projectPoint(Point{X: bounds.Max.X, Y: bounds.Min.Y}, gradientDir),
 		),
 		math.Max(
-			projectPoint(Point{MIN_X, MAX_Y}, gradientDir),
-			projectPoint(Point{MAX_X, MAX_Y}, gradientDir),
+			projectPoint(Point{X: bounds.Min.X, Y: bounds.Max.Y}, gradientDir),
+			projectPoint(Point{X: bounds.Max.X, Y: bounds.Max.Y}, gradientDir),
 		),
 	)
 
 	minProjection := math.Min(
 		math.Min(
-			projectPoint(Point{MIN_X, MIN_Y}, gradientDir),
-			projectPoint(Point{MAX_X, MIN_Y}, gradientDir),
+			projectPoint(Point{X: bounds.Min.X, Y: bounds.Min.Y}, gradientDir),
+			projectPoint(Point{X: bounds.Max.X, Y: bounds.Min.Y}, gradientDir),
 		),
 		math.Min(
-			projectPoint(Point{MIN_X, MAX_Y}, gradientDir),
-			projectPoint(Point{MAX_X, MAX_Y}, gradientDir),
+			projectPoint(Point{X: bounds.Min.X, Y: bounds.Max.Y}, gradientDir),
+			projectPoint(Point{X: bounds.Max.X, Y: bounds.Max.Y}, gradientDir),
 		),
 	)
 
@@ -213,7 +217,11 @@ func GetColorAtPointWithBlendSize(p Point, color1 Color, color2 Color, angleDegr
 	projection := projectPoint(p, gradientDir)
 
 	// normalize the projection to get a value between 0 and 1
-	t := (projection - minProjection) / (maxProjection - minProjection)
+	// a layout with no extent along the gradient sits entirely at the start of it
+	t := 0.0
+	if maxProjection > minProjection {
+		t = (projection - minProjection) / (maxProjection - minProjection)
+	}
 
 	// blendSize of 0 means sharp transition (no blend)
 	// blendSize of 1 means full gradient (maximum blend)
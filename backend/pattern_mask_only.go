@@ -19,8 +19,7 @@ func (p *MaskOnlyPattern) Update() {
 	// Apply the color mask to all pixels
 	if p.GetColorMask() != nil {
 		for i, pixel := range *p.pixelMap.pixels {
-			point := Point{pixel.x, pixel.y}
-			(*p.pixelMap.pixels)[i].color = p.GetColorMask().GetColorAt(point)
+			(*p.pixelMap.pixels)[i].color = maskColorAt(p.GetColorMask(), &pixel)
 		}
 	} else {
 		// Default to white if no mask is set
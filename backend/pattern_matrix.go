@@ -252,8 +252,8 @@ func (p *MatrixPattern) addNewDrops(xCoords []int16, density, dropLength, deltaT
 	}
 }
 
-func (p *MatrixPattern) applyColor(pixelIdx int, brightness float64, x, y int16) {
-	maskColor := p.GetColorMask().GetColorAt(Point{x, y})
+func (p *MatrixPattern) applyColor(pixelIdx int, brightness float64) {
+	maskColor := maskColorAt(p.GetColorMask(), &(*p.pixelMap.pixels)[pixelIdx])
 	(*p.pixelMap.pixels)[pixelIdx].color = Color{
 		R: colorPigment(float64(maskColor.R) * brightness),
 		G: colorPigment(float64(maskColor.G) * brightness),
@@ -306,7 +306,7 @@ func (p *MatrixPattern) drawDropSegments(pixelLookup map[int16]map[int16]int, dr
 			brightness := math.Max(0, 1.0-distFromHead/float64(drop.length)) * drop.bright
 
 			if pixelIdx, hasPixel := columnMap[y]; hasPixel {
-				p.applyColor(pixelIdx, brightness, drop.x, y)
+				p.applyColor(pixelIdx, brightness)
 			}
 
 			p.drawNearbyPixels(pixelLookup, drop, y, headY, brightness, reversed)
@@ -330,7 +330,7 @@ func (p *MatrixPattern) drawNearbyPixels(pixelLookup map[int16]map[int16]int, dr
 
 				// only draw if brightness is significant
 				if brightness > 0.1 {
-					p.applyColor(pixelIdx, brightness, drop.x+dx, y)
+					p.applyColor(pixelIdx, brightness)
 				}
 			}
 		}
@@ -343,9 +343,8 @@ func (p *MatrixPattern) addSparkles(density float64) {
 		if len(*p.pixelMap.pixels) > 0 {
 			idx := rand.Intn(len(*p.pixelMap.pixels))
 			brightness := 0.5 + rand.Float64()*0.5
-			pixel := (*p.pixelMap.pixels)[idx]
 
-			p.applyColor(idx, brightness, pixel.x, pixel.y)
+			p.applyColor(idx, brightness)
 		}
 	}
 }
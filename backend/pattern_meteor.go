@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+type MeteorPattern struct {
+	BasePattern
+	pixelMap   *PixelMap
+	positions  map[int]float64   // where the head is along each strip, by strip index
+	trails     map[int][]float64 // brightness left behind along each strip, by strip index
+	Parameters MeteorParameters  `json:"parameters"`
+	Label      string            `json:"label,omitempty"`
+}
+
+func (p *MeteorPattern) UpdateParameters(parameters AdjustableParameters) error {
+	newParams, ok := parameters.(MeteorParameters)
+	if !ok {
+		err := fmt.Sprintf("Could not cast updated parameters for %v pattern", p.GetName())
+		return errors.New(err)
+	}
+
+	p.Parameters.Speed.Update(newParams.Speed.Value)
+	p.Parameters.Size.Update(newParams.Size.Value)
+	p.Parameters.Decay.Update(newParams.Decay.Value)
+	p.Parameters.RandomDecay.Update(newParams.RandomDecay.Value)
+	p.Parameters.Reversed.Update(newParams.Reversed.Value)
+	return nil
+}
+
+type MeteorParameters struct {
+	Speed       FloatParameter   `json:"speed"`
+	Size        IntParameter     `json:"size"`
+	Decay       FloatParameter   `json:"decay"`
+	RandomDecay BooleanParameter `json:"randomDecay"`
+	Reversed    BooleanParameter `json:"reversed"`
+}
+
+func (p *MeteorPattern) Update() {
+	if p.trails == nil {
+		p.trails = make(map[int][]float64)
+		p.positions = make(map[int]float64)
+	}
+
+	renderStrips(p.pixelMap, p)
+}
+
+// a meteor falls along each strip, leaving a trail that decays unevenly behind it
+func (p *MeteorPattern) RenderStrip(strip *Strip) {
+	length := strip.Len()
+	if length == 0 {
+		return
+	}
+
+	trail, exists := p.trails[strip.index]
+	if !exists || len(trail) != length {
+		trail = make([]float64, length)
+		p.trails[strip.index] = trail
+	}
+
+	decay := p.Parameters.Decay.Value / 100.0
+	for position := range trail {
+		// random decay leaves sparkling fragments in the trail
+		if !p.Parameters.RandomDecay.Value || rand.Float64() < 0.5 {
+			trail[position] *= 1.0 - decay
+		}
+	}
+
+	// a meteor longer than the strip just lights all of it
+	current := p.positions[strip.index]
+	head := int(wrapPosition(current, length))
+	for i := 0; i < min(p.Parameters.Size.Value, length); i++ {
+		position := (head - i + length) % length
+		if p.Parameters.Reversed.Value {
+			position = length - 1 - position
+		}
+		trail[position] = 1.0
+	}
+
+	for position := 0; position < length; position++ {
+		pixel := strip.At(position)
+		brightness := trail[position]
+
+		if brightness < 0.01 {
+			pixel.color = Color{0, 0, 0, 0}
+			continue
+		}
+
+		color := Color{255, 255, 255, 0}
+		if p.GetColorMask() != nil {
+			color = maskColorAt(p.GetColorMask(), pixel)
+		}
+		pixel.color = Color{
+			R: colorPigment(float64(color.R) * brightness),
+			G: colorPigment(float64(color.G) * brightness),
+			B: colorPigment(float64(color.B) * brightness),
+			W: 0,
+		}
+	}
+
+	// each strip wraps at its own length, so the meteor falls the whole of it
+	p.positions[strip.index] = wrapPosition(current+p.Parameters.Speed.Value, length)
+}
+
+func (p *MeteorPattern) GetName() string {
+	return "meteor"
+}
+
+type MeteorUpdateRequest struct {
+	Parameters MeteorParameters `json:"parameters"`
+}
+
+func (r *MeteorUpdateRequest) GetParameters() AdjustableParameters {
+	return r.Parameters
+}
+
+func (p *MeteorPattern) GetPatternUpdateRequest() PatternUpdateRequest {
+	return &MeteorUpdateRequest{
+		Parameters: p.Parameters,
+	}
+}
+
+func (p *MeteorPattern) TransitionFrom(source Pattern, progress float64) {
+	DefaultTransitionFromPattern(p, source, progress, p.pixelMap)
+}
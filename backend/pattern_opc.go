@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OPCPattern displays frames received by the OPC server from an external generator
+type OPCPattern struct {
+	BasePattern
+	pixelMap   *PixelMap
+	frames     *OPCFrames
+	Parameters OPCParameters `json:"parameters"`
+	Label      string        `json:"label,omitempty"`
+}
+
+type OPCParameters struct {
+	// how long the last frame stays up after the generator stops sending, in seconds
+	Hold FloatParameter `json:"hold"`
+}
+
+func (p *OPCPattern) UpdateParameters(parameters AdjustableParameters) error {
+	newParams, ok := parameters.(OPCParameters)
+	if !ok {
+		err := fmt.Sprintf("Could not cast updated parameters for %v pattern", p.GetName())
+		return errors.New(err)
+	}
+
+	p.Parameters.Hold.Update(newParams.Hold.Value)
+	return nil
+}
+
+func (p *OPCPattern) Update() {
+	pixels := *p.pixelMap.pixels
+	for i := range pixels {
+		pixels[i].color = Color{0, 0, 0, 0}
+	}
+
+	hold := time.Duration(p.Parameters.Hold.Value * float64(time.Second))
+	p.frames.each(hold, func(channel uint8, data []byte) {
+		if channel == OPCBroadcastChannel {
+			for i := 0; i < len(pixels) && i*3+2 < len(data); i++ {
+				pixels[i].color = opcColor(data[i*3:])
+			}
+			return
+		}
+
+		for i, index := range p.pixelMap.universeIndexes[uint16(channel)] {
+			if i*3+2 >= len(data) {
+				break
+			}
+			pixels[index].color = opcColor(data[i*3:])
+		}
+	})
+}
+
+func opcColor(data []byte) Color {
+	return Color{R: colorPigment(data[0]), G: colorPigment(data[1]), B: colorPigment(data[2])}
+}
+
+func (p *OPCPattern) GetName() string {
+	return "opc"
+}
+
+type OPCUpdateRequest struct {
+	Parameters OPCParameters `json:"parameters"`
+}
+
+func (r *OPCUpdateRequest) GetParameters() AdjustableParameters {
+	return r.Parameters
+}
+
+func (p *OPCPattern) GetPatternUpdateRequest() PatternUpdateRequest {
+	return &OPCUpdateRequest{
+		Parameters: p.Parameters,
+	}
+}
+
+func (p *OPCPattern) TransitionFrom(source Pattern, progress float64) {
+	DefaultTransitionFromPattern(p, source, progress, p.pixelMap)
+}
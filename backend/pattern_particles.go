@@ -181,7 +181,7 @@ func (p *ParticlePattern) Update() {
 
 			// apply color mask if available
 			if p.GetColorMask() != nil {
-				maskColor := p.GetColorMask().GetColorAt(Point{pixel.x, pixel.y})
+				maskColor := maskColorAt(p.GetColorMask(), &pixel)
 
 				// blend with mask color
 				color := Color{
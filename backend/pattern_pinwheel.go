@@ -41,17 +41,17 @@ func (p *PinwheelPattern) Update() {
 	reversed := p.Parameters.Reversed.Value
 
 	for i, pixel := range *p.pixelMap.pixels {
-		point := Point{pixel.x, pixel.y}
+		point := Point{pixel.x, pixel.y, pixel.z}
 
 		// calculate rotation degrees
-		rotationDegrees := calculateAngle(point, Point{CENTER_X, CENTER_Y})
+		rotationDegrees := calculateAngle(point, p.pixelMap.origin())
 
 		// calculate saturation based on rotation
 		fractionDegrees := rotationDegrees / MAX_DEGREES * float64(divisions)
 		saturation := math.Mod(p.currentSaturation+fractionDegrees, MAX_SATURATION)
 
 		if p.GetColorMask() != nil {
-			baseColor := p.GetColorMask().GetColorAt(point)
+			baseColor := maskColorAt(p.GetColorMask(), &pixel)
 
 			// convert to HSV, modify saturation, convert back
 			h, _, v := RGBtoHSV(float64(baseColor.R)/255, float64(baseColor.G)/255, float64(baseColor.B)/255)
@@ -60,18 +60,19 @@ func (p *PlasmaPattern) Update() {
 	// calculate plasma values for each pixel
 	for i, pixel := range *p.pixelMap.pixels {
 		// normalize coordinates to -1 to 1 range
-		x := float64(pixel.x)/400.0 - 1.0
-		y := float64(pixel.y)/400.0 - 1.0
+		x := pixel.nx*2 - 1.0
+		y := pixel.ny*2 - 1.0
+		z := pixel.nz*2 - 1.0
 
 		// calculate plasma value
-		value := p.plasmaFunction(x*scale, y*scale, p.time, complexity)
+		value := p.plasmaFunction(x*scale, y*scale, z*scale, p.time, complexity)
 
 		// map plasma value to color
 		color := p.plasmaToColor(value, colorShift)
 
 		// apply color mask if available
 		if p.GetColorMask() != nil {
-			maskColor := p.GetColorMask().GetColorAt(Point{pixel.x, pixel.y})
+			maskColor := maskColorAt(p.GetColorMask(), &pixel)
 
 			// blend with mask color
 			color = Color{
@@ -91,12 +92,13 @@ func (p *PlasmaPattern) Update() {
 	}
 }
 
-func (p *PlasmaPattern) plasmaFunction(x, y, time, complexity float64) float64 {
-	// create a plasma effect using sine waves
+func (p *PlasmaPattern) plasmaFunction(x, y, z, time, complexity float64) float64 {
+	// create a plasma effect using sine waves. z only contributes to the diagonal and radial
+	// waves, so flat layouts look the same as they always have
 	v1 := math.Sin(x*complexity + time)
 	v2 := math.Sin(y*complexity + time)
-	v3 := math.Sin((x+y)*complexity + time)
-	v4 := math.Sin(math.Sqrt(x*x+y*y)*complexity + time)
+	v3 := math.Sin((x+y+z)*complexity + time)
+	v4 := math.Sin(math.Sqrt(x*x+y*y+z*z)*complexity + time)
 
 	// combine the waves
 	return (v1 + v2 + v3 + v4) / 4.0
@@ -62,8 +62,7 @@ func (p *PulsePattern) Update() {
 		if p.GetColorMask() == nil {
 			return
 		}
-		point := Point{pixel.x, pixel.y}
-		baseColor := p.GetColorMask().GetColorAt(point)
+		baseColor := maskColorAt(p.GetColorMask(), &pixel)
 
 		// apply brightness to the color from the mask
 		pixelColor = Color{
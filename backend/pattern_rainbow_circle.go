@@ -41,7 +41,7 @@ func (p *RainbowCirclePattern) Update() {
 	reversed := p.Parameters.Reversed.Value
 
 	for i, pixel := range *p.pixelMap.pixels {
-		distance := math.Sqrt(math.Pow(float64(CENTER_X-pixel.x), 2) + math.Pow(float64(CENTER_Y-pixel.y), 2))
+		distance := distanceBetweenPoints(Point{pixel.x, pixel.y, pixel.z}, p.pixelMap.origin())
 
 		hueVal := math.Mod(p.currentHue+distance, MAX_HUE_VALUE)
 		c := colorful.Hsv(hueVal, 1.0, 1.0)
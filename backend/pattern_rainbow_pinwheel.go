@@ -42,7 +42,7 @@ func (p *RainbowPinwheelPattern) Update() {
 
 	for i, pixel := range *p.pixelMap.pixels {
 
-		rotationDegrees := calculateAngle(Point{pixel.x, pixel.y}, Point{CENTER_X, CENTER_Y})
+		rotationDegrees := calculateAngle(Point{pixel.x, pixel.y, pixel.z}, p.pixelMap.origin())
 
 		hueVal := math.Mod(p.currentHue+rotationDegrees, MAX_HUE_VALUE)
 		c := colorful.Hsv(hueVal, 1.0, 1.0)
@@ -96,7 +96,7 @@ func (p *RandomPattern) startTransition() {
 func (p *RandomPattern) selectRandomPattern() {
 	var patternNames []string
 	for name := range p.patterns {
-		if name != "random" && name != "lightsOff" && (p.currentPattern == nil || name != p.currentPattern.GetName()) {
+		if name != "random" && name != "lightsOff" && name != "opc" && (p.currentPattern == nil || name != p.currentPattern.GetName()) {
 			patternNames = append(patternNames, name)
 		}
 	}
@@ -112,7 +112,7 @@ func (p *RandomPattern) selectRandomPattern() {
 }
 
 func (p *RandomPattern) selectRandomColorMask() {
-	colorMasks := registerColorMasks()
+	colorMasks := registerColorMasks(p.pixelMap)
 	if len(colorMasks) > 0 {
 		var maskNames []string
 		for name := range colorMasks {
@@ -174,7 +174,7 @@ func (p *RandomPattern) TransitionFrom(source Pattern, progress float64) {
 
 		// if randomizing color masks is enabled, select a random mask
 		if p.Parameters.RandomizeColorMasks.Value {
-			colorMasks := registerColorMasks()
+			colorMasks := registerColorMasks(p.pixelMap)
 			if len(colorMasks) > 0 {
 				var maskNames []string
 				for name := range colorMasks {
@@ -269,7 +269,7 @@ func (p *RandomPattern) randomizeParameters(pattern Pattern) {
 					fieldValue.Value = int(constrainedMax)
 				}
 
-				fmt.Printf("  %s.%s: %d -> %d (range: %d to %d, constrained: %.1f to %.1f)\n",
+				fmt.Printf("  %s.%s: %d -> %d (range: %d to %d, constrained: %d to %d)\n",
 					patternName, fieldName, oldValue, fieldValue.Value,
 					min, max, constrainedMin, constrainedMax)
 			}
@@ -100,7 +100,7 @@ func (p *RipplePattern) Update() {
 
 		// draw the ripple
 		for i, pixel := range *p.pixelMap.pixels {
-			point := Point{pixel.x, pixel.y}
+			point := Point{pixel.x, pixel.y, pixel.z}
 			dist := distanceBetweenPoints(point, r.center)
 
 			// calculate ripple effect - creates a ring shape
@@ -111,7 +111,7 @@ func (p *RipplePattern) Update() {
 			if ringEffect > 0.05 {
 				// get color from mask if available
 				if p.GetColorMask() != nil {
-					maskColor := p.GetColorMask().GetColorAt(point)
+					maskColor := maskColorAt(p.GetColorMask(), &pixel)
 
 					// blend with background based on ring effect
 					blendedColor := blendColors(backgroundColor, maskColor, ringEffect)
@@ -138,22 +138,18 @@ func (p *RipplePattern) Update() {
 }
 
 func (p *RipplePattern) addRandomRipple() {
-	// create a ripple at a random position
+	// create a ripple at a random position. on layouts with depth the ripple is a sphere,
+	// so it can start anywhere inside the sculpture
+	bounds := p.pixelMap.bounds()
 	center := Point{
-		X: int16(rand.Intn(MAX_X)),
-		Y: int16(rand.Intn(MAX_Y)),
+		X: bounds.Min.X + int16(rand.Intn(int(bounds.Width())+1)),
+		Y: bounds.Min.Y + int16(rand.Intn(int(bounds.Height())+1)),
+		Z: bounds.Min.Z + int16(rand.Intn(int(bounds.Depth())+1)),
 	}
 
 	// calculate maximum radius based on distance to furthest corner
-	corners := []Point{
-		{0, 0},
-		{int16(MAX_X), 0},
-		{0, int16(MAX_Y)},
-		{int16(MAX_X), int16(MAX_Y)},
-	}
-
 	maxRadius := 0.0
-	for _, corner := range corners {
+	for _, corner := range bounds.Corners() {
 		dist := distanceBetweenPoints(center, corner)
 		if dist > maxRadius {
 			maxRadius = dist
@@ -53,9 +53,10 @@ func (p *SparklePattern) Update() {
 
 	if len(p.sparkles) < MAX_SPARKLES {
 		if randomChancePercent(SPARKLE_CHANCE_TO_CREATE) {
+			bounds := p.pixelMap.bounds()
 			p.sparkles = append(p.sparkles, &Sparkle{
-				x:        rand.IntN(MAX_X),
-				y:        rand.IntN(MAX_Y),
+				x:        int(bounds.Min.X) + rand.IntN(int(bounds.Width())+1),
+				y:        int(bounds.Min.Y) + rand.IntN(int(bounds.Height())+1),
 				rotation: SPARKLE_DEFAULT_ROTATION,
 				size:     SPARKLE_STARTING_SIZE,
 				speed:    rand.Float64() * MAX_SPARKLE_SPEED,
@@ -94,12 +95,12 @@ func (p *SparklePattern) Update() {
 	}
 
 	for i, pixel := range *p.pixelMap.pixels {
-		point := Point{pixel.x, pixel.y}
+		point := Point{pixel.x, pixel.y, pixel.z}
 		if pointIsBetweenAnySparkle(point, p.sparkles) {
 			if p.GetColorMask() == nil {
 				return
 			}
-			(*p.pixelMap.pixels)[i].color = p.GetColorMask().GetColorAt(point)
+			(*p.pixelMap.pixels)[i].color = maskColorAt(p.GetColorMask(), &pixel)
 		} else {
 			(*p.pixelMap.pixels)[i].color = Color{0, 0, 0, 0}
 		}
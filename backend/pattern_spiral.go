@@ -25,6 +25,7 @@ func (p *SpiralPattern) UpdateParameters(parameters AdjustableParameters) error
 	p.Parameters.BackgroundColor.Update(newParams.BackgroundColor.Value)
 	p.Parameters.MaxTurns.Update(newParams.MaxTurns.Value)
 	p.Parameters.Width.Update(newParams.Width.Value)
+	p.Parameters.Twist.Update(newParams.Twist.Value)
 	return nil
 }
 
@@ -33,27 +34,34 @@ type SpiralParameters struct {
 	BackgroundColor ColorParameter `json:"backgroundColor"`
 	MaxTurns        IntParameter   `json:"maxTurns"`
 	Width           FloatParameter `json:"width"`
+	Twist           FloatParameter `json:"twist"` // degrees of extra rotation per unit of depth
 }
 
 func (p *SpiralPattern) Update() {
 	backgroundColor := p.Parameters.BackgroundColor.Value
 	speed := p.Parameters.Speed.Value
 	width := p.Parameters.Width.Value
+	twist := p.Parameters.Twist.Value
 
 	params := SpiralParams{
-		Radius:       0,
-		Width:        width,
-		MaxTurns:     float64(p.Parameters.MaxTurns.Value),
-		Rotation:     p.currentRotation,
-		Center:       Point{400, 400},
-		QuadrantSize: 800,
+		Radius:    0,
+		Width:     width,
+		MaxTurns:  float64(p.Parameters.MaxTurns.Value),
+		Rotation:  p.currentRotation,
+		Center:    p.pixelMap.origin(),
+		MaxRadius: p.pixelMap.maxRadius(),
 	}
 
 	for i, pixel := range *p.pixelMap.pixels {
-		point := Point{pixel.x, pixel.y}
-		if isPointBetweenSpirals(point, params) {
+		point := Point{pixel.x, pixel.y, pixel.z}
+
+		// on layouts with depth the spiral becomes a helix, turning as it rises
+		pixelParams := params
+		pixelParams.Rotation += twist * float64(pixel.z)
+
+		if isPointBetweenSpirals(point, pixelParams) {
 			if p.GetColorMask() != nil {
-				(*p.pixelMap.pixels)[i].color = p.GetColorMask().GetColorAt(point)
+				(*p.pixelMap.pixels)[i].color = maskColorAt(p.GetColorMask(), &pixel)
 			}
 		} else {
 			(*p.pixelMap.pixels)[i].color = backgroundColor
@@ -84,19 +92,18 @@ func (p *SpiralPattern) GetPatternUpdateRequest() PatternUpdateRequest {
 
 // contains all parameters needed to define our spiral
 type SpiralParams struct {
-	Radius       float64 // starting radius
-	Width        float64 // width of the spiral
-	MaxTurns     float64 // maximum number of turns
-	Rotation     float64 // angle
-	Center       Point   // center of the spiral
-	QuadrantSize float64 // size of the quadrant
+	Radius    float64 // starting radius
+	Width     float64 // width of the spiral
+	MaxTurns  float64 // maximum number of turns
+	Rotation  float64 // angle
+	Center    Point   // center of the spiral
+	MaxRadius float64 // distance from the center to the farthest corner of the layout
 }
 
 // calculate the growth rate (b) needed to fill the window for given turns
 func calculateGrowthRate(params SpiralParams) float64 {
-	maxRadius := math.Sqrt(2) * 400 // half of 800x800 window
 	maxTheta := params.MaxTurns * 2 * math.Pi
-	return (maxRadius - params.Radius) / maxTheta
+	return (params.MaxRadius - params.Radius) / maxTheta
 }
 
 // convert from cartesian to polar coordinates
@@ -6,9 +6,6 @@ import (
 	"math"
 )
 
-const STRIPES_STARTING_POSITION = 0
-const STRIPES_ENDING_POSITION = MAX_X
-
 type StripesPattern struct {
 	BasePattern     // Embed the base pattern
 	pixelMap        *PixelMap
@@ -44,7 +41,11 @@ func (p *StripesPattern) Update() {
 	rotation := p.Parameters.Rotation.Value
 	stripes := p.Parameters.Stripes.Value
 
-	maxPosition := float64(STRIPES_ENDING_POSITION - STRIPES_STARTING_POSITION)
+	// stripes travel across the width of the layout and rotate around its origin
+	bounds := p.pixelMap.bounds()
+	origin := p.pixelMap.origin()
+	maxPosition := max(bounds.Width(), 1)
+	stripeLength := 2 * p.pixelMap.maxRadius()
 	spaceBetweenStripes := maxPosition / float64(stripes)
 	positions := []float64{}
 
@@ -52,14 +53,14 @@ func (p *StripesPattern) Update() {
 	for i := 0; i < stripes; i++ {
 		position := p.currentPosition + (float64(i) * spaceBetweenStripes)
 		position = math.Mod(position, maxPosition)
-		positions = append(positions, position)
+		positions = append(positions, float64(bounds.Min.X)+position)
 	}
 
 	for i, pixel := range *p.pixelMap.pixels {
-		point := Point{pixel.x, pixel.y}
-		if isInAnyBox(point, size, rotation, positions) {
+		point := Point{pixel.x, pixel.y, pixel.z}
+		if isInAnyBox(point, origin, size, stripeLength, rotation, positions) {
 			if p.colorMask != nil {
-				(*p.pixelMap.pixels)[i].color = p.colorMask.GetColorAt(point)
+				(*p.pixelMap.pixels)[i].color = maskColorAt(p.colorMask, &pixel)
 			} else {
 				(*p.pixelMap.pixels)[i].color = Color{255, 255, 255, 0} // Default white if no mask
 			}
@@ -71,9 +72,9 @@ func (p *StripesPattern) Update() {
 	p.currentPosition = math.Mod(p.currentPosition+speed, float64(maxPosition))
 }
 
-func isInAnyBox(point Point, size float64, rotation float64, positions []float64) bool {
+func isInAnyBox(point Point, center Point, size float64, length float64, rotation float64, positions []float64) bool {
 	for _, position := range positions {
-		if isInBox(point, size, rotation, position) {
+		if isInBox(point, center, size, length, rotation, position) {
 			return true
 		}
 	}
@@ -81,16 +82,16 @@ func isInAnyBox(point Point, size float64, rotation float64, positions []float64
 	return false
 }
 
-func isInBox(point Point, size float64, rotation float64, position float64) bool {
+func isInBox(point Point, center Point, size float64, length float64, rotation float64, position float64) bool {
 
 	// the rotation will impact the starting position. we want to start approximately 2x away from the center
 	// and always move towards the center
-	rotationCenterX := float64(CENTER_X)
-	rotationCenterY := float64(CENTER_Y)
-	boxCenterX := STRIPES_STARTING_POSITION + position
-	boxCenterY := float64(CENTER_Y)
+	rotationCenterX := float64(center.X)
+	rotationCenterY := float64(center.Y)
+	boxCenterX := position
+	boxCenterY := float64(center.Y)
 	boxWidth := size
-	boxHeight := float64(MAX_Y)
+	boxHeight := length
 
 	angleInRadians := degreesToRadians(rotation) / 2
 
@@ -18,18 +18,17 @@ const (
 	GBR
 )
 
-// viewing area is approx 800x800.
+// viewing area is approx 800x800. imported layouts are scaled into it by default, but
+// patterns work from the bounds the pixel map measures for itself (see geometry.go)
 const MIN_X = 0
 const MAX_X = 800
 const MIN_Y = 0
 const MAX_Y = 800
 
-const CENTER_X = (MIN_X + MAX_X) / 2
-const CENTER_Y = (MIN_Y + MAX_Y) / 2
-
 type Section struct {
-	name  string
-	label string
+	name   string
+	label  string
+	parent string // enclosing section, if nested
 }
 
 // PixelType defines whether a pixel is RGB (3 channels) or RGBW (4 channels)
@@ -40,9 +39,38 @@ const (
 	PixelRGBW PixelType = 4 // 4 channels
 )
 
+func (t PixelType) String() string {
+	if t == PixelRGB {
+		return "rgb"
+	}
+	return "rgbw"
+}
+
+func (o ColorOrder) String() string {
+	switch o {
+	case RBG:
+		return "RBG"
+	case BRG:
+		return "BRG"
+	case BGR:
+		return "BGR"
+	case GRB:
+		return "GRB"
+	case GBR:
+		return "GBR"
+	}
+	return "RGB"
+}
+
 type Pixel struct {
 	x               int16
 	y               int16
+	z               int16   // depth, zero for flat layouts
+	nx, ny, nz      float64 // position normalised to 0..1 within the map's bounds
+	size            float64 // diameter of the area the pixel lights, zero for a point
+	weight          float64 // light output relative to other pixels, zero means 1
+	compensation    float64 // brightness scale that evens out pixels of different weights
+	sampled         bool    // big enough next to the rest of the layout for masks to average over
 	color           Color
 	colorOrder      ColorOrder // TODO: implement color correction based on color ordering
 	pixelType       PixelType  // RGB or RGBW
@@ -52,11 +80,22 @@ type Pixel struct {
 }
 
 type PixelMap struct {
-	pixels *[]Pixel
+	pixels   *[]Pixel
+	segments []PixelSegment
+	strips   []Strip
+	sections map[string]Section
+	groups   []SectionConfig // section definitions, re-applied when pixels are rebuilt
+	ddp      []DDPConfig     // devices sent whole pixel buffers rather than universes
+	opc      []OPCConfig     // servers sent every frame over open pixel control
+	wled     []WLEDConfig    // nodes sent realtime frames over UDP rather than universes
+	geometry Geometry
+
+	// indexes of each universe's pixels in channel order, kept up to date by the controller
+	universeIndexes map[uint16][]int
 }
 
 type Point struct {
-	X, Y int16
+	X, Y, Z int16
 }
 
 func calculateAngle(target Point, center Point) float64 {
@@ -3,17 +3,44 @@ package main
 import (
 	"fmt"
 	"log"
+	"maps"
 	"math"
+	"sort"
 	"sync"
 	"time"
 )
 
+// the pixels of a universe, each with the offset of its first channel, and the buffer the
+// universe's frames are written to. publishing a frame copies it, so one buffer is reused
+// for every frame.
+type universeLayout struct {
+	pixels  []*Pixel
+	offsets []int
+	indexes []int // of each pixel in the pixel map
+	buffer  []byte
+}
+
+// the pixels sent to a device, in buffer order
+type deviceLayout struct {
+	pixels []*Pixel
+	rgb    bool // three channels per pixel in RGB order whatever the pixel, for OPC
+	buffer []byte
+}
+
 // PixelController manages the updating and display of pixels across universes
 type PixelController struct {
-	universes        map[uint16]chan<- []byte
+	universes        map[uint16]*FrameSlot
+	devices          map[string]*FrameSlot // DDP devices, OPC servers and WLED nodes, sent whole buffers
+	syncUniverses    func() error          // called once every universe of a frame is written
+	mergeInput       func(universe uint16, data []byte) []byte
+	changesOnly      bool // universes are only published when their data changes
+	recorder         *FrameRecorder
+	recorderMu       sync.Mutex
 	patterns         map[string]Pattern
 	errorTracker     *ErrorTracker
-	pixelsByUniverse map[uint16][]*Pixel
+	pixelsByUniverse map[uint16]*universeLayout
+	deviceLayouts    map[string]*deviceLayout
+	frame            map[uint16][]byte // the universes written in the current frame, for recording
 	updateInterval   time.Duration
 	running          bool
 	stopChan         chan struct{}
@@ -33,16 +60,24 @@ type PixelController struct {
 		sourceMask    ColorMaskPattern
 		targetMask    ColorMaskPattern
 	}
+	fadeOut *struct {
+		startTime time.Time
+		duration  time.Duration
+	}
 	transitionMutex    sync.RWMutex
 	transitionDuration time.Duration
 	patternChange      chan Pattern
 	currentColorMask   ColorMaskPattern
 	colorMaskChange    chan ColorMaskPattern
 	isParameterUpdate  bool
+	layoutMutex        sync.RWMutex
+	segmentEditMutex   sync.Mutex // one segment edit at a time, so segments can be rebuilt outside layoutMutex
+	zones              zoneSet
 }
 
-func getDefaultColorMask() ColorMaskPattern {
+func getDefaultColorMask(pixelMap *PixelMap) ColorMaskPattern {
 	return &RainbowCircleMask{
+		pixelMap: pixelMap,
 		BasePattern: BasePattern{
 			Label: "Rainbow Circle",
 		},
@@ -67,7 +102,7 @@ func getDefaultColorMask() ColorMaskPattern {
 	}
 }
 
-func NewPixelController(universes map[uint16]chan<- []byte, errorTracker *ErrorTracker, fps int, initialPattern Pattern, pixelMap *PixelMap, options Options) *PixelController {
+func NewPixelController(universes map[uint16]*FrameSlot, errorTracker *ErrorTracker, fps int, initialPattern Pattern, pixelMap *PixelMap, options Options) *PixelController {
 	if initialPattern == nil {
 		panic("initialPattern cannot be nil")
 	}
@@ -82,10 +117,11 @@ func NewPixelController(universes map[uint16]chan<- []byte, errorTracker *ErrorT
 		options:          options,
 		patternChange:    make(chan Pattern, 1),
 		colorMaskChange:  make(chan ColorMaskPattern, 1),
-		currentColorMask: getDefaultColorMask(),
+		currentColorMask: getDefaultColorMask(pixelMap),
 	}
 
 	controller.patterns = registerPatterns(pixelMap)
+	controller.applyOriginOptions()
 	return controller
 }
 
@@ -97,98 +133,215 @@ func NewPixelController(universes map[uint16]chan<- []byte, errorTracker *ErrorT
 // expensive lookups
 
 func (pc *PixelController) organizePixelsByUniverse(pixelMap *PixelMap) {
-	pc.pixelsByUniverse = make(map[uint16][]*Pixel)
-	for i, pixel := range *pixelMap.pixels {
-		pc.pixelsByUniverse[pixel.universe] = append(
-			pc.pixelsByUniverse[pixel.universe],
-			&(*pixelMap.pixels)[i],
-		)
+	pc.pixelsByUniverse = make(map[uint16]*universeLayout)
+	deviceSegments := deviceSegments(pixelMap.ddp, pixelMap.wled)
+	for _, segment := range pixelMap.segments {
+		if deviceSegments[segment.config.Name] {
+			continue
+		}
+		for i := segment.start; i < segment.start+segment.count; i++ {
+			pixel := &(*pixelMap.pixels)[i]
+
+			// pixels that don't fit in the universe are never sent
+			channelsPerPixel := int(pixel.pixelType) // 3 for RGB, 4 for RGBW
+			offset := (int(pixel.channelPosition) - 1) * channelsPerPixel
+			if offset < 0 || offset+channelsPerPixel > UniverseChannels {
+				continue
+			}
+
+			layout, exists := pc.pixelsByUniverse[pixel.universe]
+			if !exists {
+				layout = &universeLayout{}
+				pc.pixelsByUniverse[pixel.universe] = layout
+			}
+			layout.pixels = append(layout.pixels, pixel)
+			layout.offsets = append(layout.offsets, offset)
+			layout.indexes = append(layout.indexes, i)
+		}
+	}
+	pixelMap.universeIndexes = universeIndexes(pc.pixelsByUniverse)
+
+	// every universe gets a buffer, even one without pixels
+	for universe := range pc.universes {
+		layout, exists := pc.pixelsByUniverse[universe]
+		if !exists {
+			layout = &universeLayout{}
+			pc.pixelsByUniverse[universe] = layout
+		}
+		layout.buffer = make([]byte, UniverseChannels)
+	}
+	pc.frame = make(map[uint16][]byte, len(pc.universes))
+
+	// devices get their pixels in buffer order instead
+	pc.deviceLayouts = make(map[string]*deviceLayout)
+	for _, device := range pixelMap.ddp {
+		pc.deviceLayouts[device.Name] = &deviceLayout{pixels: pixelMap.ddpDevicePixels(device)}
+	}
+	for _, server := range pixelMap.opc {
+		pc.deviceLayouts[server.Name] = &deviceLayout{pixels: pixelMap.opcPixels(server), rgb: true}
+	}
+	for _, node := range pixelMap.wled {
+		pc.deviceLayouts[node.Name] = &deviceLayout{pixels: pixelMap.wledPixels(node), rgb: true}
+	}
+	for _, layout := range pc.deviceLayouts {
+		length := len(layout.pixels) * 3
+		if !layout.rgb {
+			length = 0
+			for _, pixel := range layout.pixels {
+				length += int(pixel.pixelType)
+			}
+		}
+		layout.buffer = make([]byte, length)
 	}
 }
 
-// prepares the byte data for a specific universe
-func (pc *PixelController) prepareUniverseData(universe uint16) []byte {
-	bytes := make([]byte, 512)
+// puts each universe's pixel indexes in channel order, for the opc pattern and OPC servers
+// sent universes
+func universeIndexes(layouts map[uint16]*universeLayout) map[uint16][]int {
+	indexes := make(map[uint16][]int, len(layouts))
+	for universe, layout := range layouts {
+		order := make([]int, len(layout.indexes))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return layout.offsets[order[i]] < layout.offsets[order[j]] })
 
-	// set all bytes to zero initially
-	for i := range bytes {
-		bytes[i] = 0
+		indexes[universe] = make([]int, len(order))
+		for i, position := range order {
+			indexes[universe][i] = layout.indexes[position]
+		}
 	}
+	return indexes
+}
+
+// SetUniverseSync sets what's called once every universe of a frame has been written, so
+// synchronized receivers can show the whole frame at once
+func (pc *PixelController) SetUniverseSync(sync func() error) {
+	pc.syncUniverses = sync
+}
 
-	// now write the pixel data
-	for _, pixel := range pc.pixelsByUniverse[universe] {
-		// calculate the actual DMX position based on channel position and pixel type
-		channelsPerPixel := int(pixel.pixelType) // 3 for RGB, 4 for RGBW
-		pos := (pixel.channelPosition - 1) * uint16(channelsPerPixel)
+// SetInputMerge sets how received input is merged into each universe before it's sent
+func (pc *PixelController) SetInputMerge(merge func(universe uint16, data []byte) []byte) {
+	pc.mergeInput = merge
+}
 
-		// write color values to consecutive channels based on color ordering
-		if pos+uint16(channelsPerPixel)-1 < 512 {
-			// Apply color correction based on pixel's sections
-			correctedColor := pc.applyColorCorrection(pixel.color, pixel.sections)
+// SetChangesOnly sets whether universes are only published when their data has changed.
+// outputs keep resending the last data of unchanged universes on their keep-alive cadence.
+func (pc *PixelController) SetChangesOnly(changesOnly bool) {
+	pc.changesOnly = changesOnly
+}
 
-			// map the color values according to the pixel's color order
-			var colorValues [4]byte
+// SetDevices sets the DDP devices, OPC servers and WLED nodes the controller sends to, alongside its
+// universes
+func (pc *PixelController) SetDevices(devices map[string]*FrameSlot) {
+	pc.devices = devices
+}
 
-			// Use the corrected color values, but force W to 0
-			colorValues[0] = byte(correctedColor.R)
-			colorValues[1] = byte(correctedColor.G)
-			colorValues[2] = byte(correctedColor.B)
-			if pixel.pixelType == PixelRGBW {
-				colorValues[3] = 0 // Always force W to 0
-			}
+// prepares the byte data for a specific universe, in the universe's buffer
+func (pc *PixelController) prepareUniverseData(universe uint16, correction *ColorCorrectionOption) []byte {
+	layout := pc.pixelsByUniverse[universe]
+	bytes := layout.buffer
+	clear(bytes)
 
-			switch pixel.colorOrder {
-			case RGB:
-				// already set correctly
-			case RBG:
-				colorValues[1], colorValues[2] = colorValues[2], colorValues[1]
-			case BRG:
-				r, g, b := colorValues[0], colorValues[1], colorValues[2]
-				colorValues[0] = b
-				colorValues[1] = r
-				colorValues[2] = g
-			case BGR:
-				r, g, b := colorValues[0], colorValues[1], colorValues[2]
-				colorValues[0] = b
-				colorValues[1] = g
-				colorValues[2] = r
-			case GRB:
-				r, g, b := colorValues[0], colorValues[1], colorValues[2]
-				colorValues[0] = g
-				colorValues[1] = r
-				colorValues[2] = b
-			case GBR:
-				r, g, b := colorValues[0], colorValues[1], colorValues[2]
-				colorValues[0] = g
-				colorValues[1] = b
-				colorValues[2] = r
-			}
+	// write color values to consecutive channels based on color ordering
+	for i, pixel := range layout.pixels {
+		pc.writePixel(correction, pixel, bytes[layout.offsets[i]:])
+	}
 
-			// write the remapped values to the output buffer
-			for i := 0; i < channelsPerPixel; i++ {
-				bytes[pos+uint16(i)] = colorValues[i]
-			}
+	return bytes
+}
+
+// prepares the buffer for a device, its pixels back to back in buffer order
+func (pc *PixelController) prepareDeviceData(device string, correction *ColorCorrectionOption) []byte {
+	layout := pc.deviceLayouts[device]
+	bytes := layout.buffer
+
+	if layout.rgb {
+		for i, pixel := range layout.pixels {
+			color := pc.applyColorCorrection(correction, pixel.color, pixel.sections)
+			bytes[i*3], bytes[i*3+1], bytes[i*3+2] = byte(color.R), byte(color.G), byte(color.B)
 		}
+		return bytes
+	}
+
+	pos := 0
+	for _, pixel := range layout.pixels {
+		pc.writePixel(correction, pixel, bytes[pos:])
+		pos += int(pixel.pixelType)
 	}
 
 	return bytes
 }
 
-// applyColorCorrection applies section-specific color correction
-func (pc *PixelController) applyColorCorrection(color Color, sections []Section) Color {
-	// Get color correction options
+// writes a pixel's color corrected channels to the start of the buffer in its color order
+func (pc *PixelController) writePixel(correction *ColorCorrectionOption, pixel *Pixel, bytes []byte) {
+	channelsPerPixel := int(pixel.pixelType) // 3 for RGB, 4 for RGBW
+
+	// Apply color correction based on pixel's sections
+	correctedColor := pc.applyColorCorrection(correction, pixel.color, pixel.sections)
+
+	// map the color values according to the pixel's color order
+	var colorValues [4]byte
+
+	// Use the corrected color values, but force W to 0
+	colorValues[0] = byte(correctedColor.R)
+	colorValues[1] = byte(correctedColor.G)
+	colorValues[2] = byte(correctedColor.B)
+	if pixel.pixelType == PixelRGBW {
+		colorValues[3] = 0 // Always force W to 0
+	}
+
+	switch pixel.colorOrder {
+	case RGB:
+		// already set correctly
+	case RBG:
+		colorValues[1], colorValues[2] = colorValues[2], colorValues[1]
+	case BRG:
+		r, g, b := colorValues[0], colorValues[1], colorValues[2]
+		colorValues[0] = b
+		colorValues[1] = r
+		colorValues[2] = g
+	case BGR:
+		r, g, b := colorValues[0], colorValues[1], colorValues[2]
+		colorValues[0] = b
+		colorValues[1] = g
+		colorValues[2] = r
+	case GRB:
+		r, g, b := colorValues[0], colorValues[1], colorValues[2]
+		colorValues[0] = g
+		colorValues[1] = r
+		colorValues[2] = b
+	case GBR:
+		r, g, b := colorValues[0], colorValues[1], colorValues[2]
+		colorValues[0] = g
+		colorValues[1] = b
+		colorValues[2] = r
+	}
+
+	// write the remapped values to the output buffer
+	for i := 0; i < channelsPerPixel; i++ {
+		bytes[i] = colorValues[i]
+	}
+}
+
+// returns the color correction option, looked up once a frame rather than for every pixel.
+// it's nil when color correction is off.
+func (pc *PixelController) colorCorrection() *ColorCorrectionOption {
 	correctionOpt, err := pc.options.GetOption("colorCorrection")
 	if err != nil {
-		return color // Return original if option not found
+		return nil
 	}
 
 	colorCorrectionOpt, ok := correctionOpt.(*ColorCorrectionOption)
-	if !ok {
-		return color // Return original if option is not the right type
+	if !ok || !colorCorrectionOpt.Value.Enabled {
+		return nil
 	}
+	return colorCorrectionOpt
+}
 
-	// Check if color correction is enabled
-	if !colorCorrectionOpt.Value.Enabled {
+// applyColorCorrection applies section-specific color correction
+func (pc *PixelController) applyColorCorrection(colorCorrectionOpt *ColorCorrectionOption, color Color, sections []Section) Color {
+	if colorCorrectionOpt == nil {
 		return color
 	}
 
@@ -231,16 +384,40 @@ func (pc *PixelController) updateAllUniverses() error {
 	pc.transitionMutex.RLock()
 	defer pc.transitionMutex.RUnlock()
 
+	// layout edits happen between frames
+	pc.layoutMutex.RLock()
+	defer pc.layoutMutex.RUnlock()
+
 	pc.Update()
 
 	if pc.onUpdate != nil {
 		pc.onUpdate(pc.pixelMap)
 	}
 
-	// send updated pixels to universes
-	for universe := range pc.universes {
-		data := pc.prepareUniverseData(universe)
-		pc.universes[universe] <- data
+	// publish updated pixels to universes, without waiting on slow outputs
+	correction := pc.colorCorrection()
+	for universe, slot := range pc.universes {
+		data := pc.prepareUniverseData(universe, correction)
+		if pc.mergeInput != nil {
+			data = pc.mergeInput(universe, data)
+		}
+		if pc.changesOnly {
+			slot.PublishChanged(data)
+		} else {
+			slot.Publish(data)
+		}
+		pc.frame[universe] = data
+	}
+	pc.recordFrame(pc.frame)
+	if pc.syncUniverses != nil {
+		if err := pc.syncUniverses(); err != nil {
+			return err
+		}
+	}
+
+	// and whole buffers to DDP devices, OPC servers and WLED nodes
+	for device, slot := range pc.devices {
+		slot.Publish(pc.prepareDeviceData(device, correction))
 	}
 
 	return nil
@@ -254,6 +431,14 @@ func (pc *PixelController) Start(pixelMap *PixelMap) error {
 	pc.running = true
 	pc.organizePixelsByUniverse(pixelMap)
 
+	// a frame still waiting for its output when the next is due is late
+	for _, slot := range pc.universes {
+		slot.SetInterval(pc.updateInterval)
+	}
+	for _, slot := range pc.devices {
+		slot.SetInterval(pc.updateInterval)
+	}
+
 	pc.wg.Add(1)
 	go func() {
 		defer pc.wg.Done()
@@ -285,6 +470,24 @@ func (pc *PixelController) Stop() {
 	pc.running = false
 }
 
+// FadeOut dims the output to black over the duration, returning once a black frame has gone
+// out. the fade can't be undone, it's only meant for shutting down.
+func (pc *PixelController) FadeOut(duration time.Duration) {
+	if !pc.running || duration <= 0 {
+		return
+	}
+
+	pc.transitionMutex.Lock()
+	pc.fadeOut = &struct {
+		startTime time.Time
+		duration  time.Duration
+	}{time.Now(), duration}
+	pc.transitionMutex.Unlock()
+
+	// give the last frame time to be rendered and sent
+	time.Sleep(duration + 2*pc.updateInterval)
+}
+
 func (pc *PixelController) SetPattern(pattern interface{}) error {
 	switch p := pattern.(type) {
 	case Pattern:
@@ -334,6 +537,15 @@ func (pc *PixelController) SetColorMask(mask ColorMaskPattern) error {
 }
 
 func (pc *PixelController) Update() {
+	pc.renderFrame()
+	pc.renderZones()
+
+	// After all pattern updates are done, apply brightness scaling to the pixel map
+	pc.applyBrightnessToPixelMap()
+}
+
+// runs the current pattern and color mask for one frame, including any transition
+func (pc *PixelController) renderFrame() {
 	// check for color mask changes
 	var newMask ColorMaskPattern
 	select {
@@ -390,6 +602,7 @@ func (pc *PixelController) Update() {
 
 		// don't create transition if we're just updating parameters or transitions are disabled
 		if pc.isParameterUpdate || !patternTransitionEnabled {
+			pc.currentPattern = newPattern
 			break
 		}
 		// create transition pixels
@@ -511,9 +724,6 @@ func (pc *PixelController) Update() {
 	if pc.currentPattern != nil {
 		pc.currentPattern.Update()
 	}
-
-	// After all pattern updates are done, apply brightness scaling to the pixel map
-	pc.applyBrightnessToPixelMap()
 }
 
 func (pc *PixelController) applyBrightnessToPixelMap() {
@@ -521,31 +731,42 @@ func (pc *PixelController) applyBrightnessToPixelMap() {
 	brightnessOpt, err := pc.options.GetOption("brightness")
 	var brightnessScale float64 = 1.0
 	if err == nil {
-		brightnessScale = brightnessOpt.GetValue().(float64) / 100.0
+		brightnessScale = brightnessOpt.(*FloatOption).Value / 100.0
 	}
 
 	// Get gamma correction option
 	gammaOpt, err := pc.options.GetOption("gamma")
 	var gamma float64 = 1.0
 	if err == nil {
-		gamma = gammaOpt.GetValue().(float64)
+		gamma = gammaOpt.(*FloatOption).Value
+	}
+
+	// fading out for shutdown scales everything down to black
+	if pc.fadeOut != nil {
+		progress := float64(time.Since(pc.fadeOut.startTime)) / float64(pc.fadeOut.duration)
+		brightnessScale *= max(0, 1-progress)
 	}
 
-	// Store original colors in a local variable for this function
-	originalColors := make([]Color, len(*pc.pixelMap.pixels))
-	for i, pixel := range *pc.pixelMap.pixels {
-		originalColors[i] = pixel.color
+	compensate := true
+	if compensationOpt, err := pc.options.GetOption("weightCompensation"); err == nil {
+		compensate = compensationOpt.GetValue().(bool)
 	}
 
 	// Apply brightness and gamma to each pixel
 	for i := range *pc.pixelMap.pixels {
-		originalColor := originalColors[i]
+		originalColor := (*pc.pixelMap.pixels)[i].color
 
 		// First apply brightness
 		r := float64(originalColor.R) * brightnessScale
 		g := float64(originalColor.G) * brightnessScale
 		b := float64(originalColor.B) * brightnessScale
 
+		if compensation := (*pc.pixelMap.pixels)[i].compensation; compensate && compensation > 0 {
+			r *= compensation
+			g *= compensation
+			b *= compensation
+		}
+
 		// Then apply gamma correction
 		if gamma != 1.0 {
 			// Normalize to 0-1 range
@@ -737,6 +958,151 @@ func (pc *PixelController) UpdateOptions(options Options) {
 	pc.patternMu.Unlock()
 
 	pc.SetTransitionDuration(options.TransitionDuration)
+	pc.applyOriginOptions()
+	pc.updateZoneOptions(&options)
+}
+
+// moves the origin radial patterns are drawn around, either following the centroid of the
+// layout or pinned to a position given as a percentage of its bounds
+func (pc *PixelController) applyOriginOptions() {
+	atCentroid := true
+	if opt, err := pc.options.GetOption("originAtCentroid"); err == nil {
+		atCentroid = opt.GetValue().(bool)
+	}
+	originX, originY := 50.0, 50.0
+	if opt, err := pc.options.GetOption("originX"); err == nil {
+		originX = opt.GetValue().(float64)
+	}
+	if opt, err := pc.options.GetOption("originY"); err == nil {
+		originY = opt.GetValue().(float64)
+	}
+
+	pc.layoutMutex.Lock()
+	defer pc.layoutMutex.Unlock()
+	pc.pixelMap.setOrigin(atCentroid, originX, originY)
+}
+
+// ValidateLayout checks the pixel map against the universes this controller outputs to
+func (pc *PixelController) ValidateLayout() LayoutReport {
+	pc.layoutMutex.RLock()
+	defer pc.layoutMutex.RUnlock()
+
+	return validateLayout(pc.pixelMap, pc.activeUniverses())
+}
+
+func (pc *PixelController) activeUniverses() []uint16 {
+	universes := make([]uint16, 0, len(pc.universes))
+	for universe := range pc.universes {
+		universes = append(universes, universe)
+	}
+	sort.Slice(universes, func(i, j int) bool { return universes[i] < universes[j] })
+	return universes
+}
+
+// LayoutSnapshot returns the current pixel metadata and segment structure
+func (pc *PixelController) LayoutSnapshot() LayoutSnapshot {
+	pc.layoutMutex.RLock()
+	defer pc.layoutMutex.RUnlock()
+
+	return pc.pixelMap.snapshot()
+}
+
+var (
+	ErrSegmentNotFound = NewError("segment not found")
+	ErrInvalidLayout   = NewError("layout change fails validation")
+)
+
+// UpdateSegment regenerates a segment with a new position, rotation or address. the change is
+// validated against the rest of the layout and swapped in between frames.
+func (pc *PixelController) UpdateSegment(name string, request SegmentUpdateRequest) (LayoutReport, error) {
+	pc.segmentEditMutex.Lock()
+	defer pc.segmentEditMutex.Unlock()
+
+	pc.layoutMutex.RLock()
+	index, err := pc.pixelMap.findSegment(name)
+	if err != nil {
+		pc.layoutMutex.RUnlock()
+		return LayoutReport{}, ErrSegmentNotFound
+	}
+	segment := pc.pixelMap.segments[index]
+	sections := maps.Clone(pc.pixelMap.sections)
+	device := deviceSegments(pc.pixelMap.ddp, pc.pixelMap.wled)[name]
+	pc.layoutMutex.RUnlock()
+
+	if err := request.checkGenerator(segment.config); err != nil {
+		return LayoutReport{}, err
+	}
+	updated := request.apply(segment.config)
+
+	if _, exists := pc.universes[updated.Universe]; !exists && !device {
+		return LayoutReport{}, fmt.Errorf("universe %d is not active", updated.Universe)
+	}
+
+	// imported segments can read their source file again, so they're built before rendering
+	// is held up
+	pixels, err := buildSegment(updated, sections)
+	if err != nil {
+		return LayoutReport{}, err
+	}
+	if len(*pixels) != segment.count {
+		return LayoutReport{}, fmt.Errorf("segment %s now has %d pixels instead of %d", name, len(*pixels), segment.count)
+	}
+
+	pc.layoutMutex.Lock()
+	defer pc.layoutMutex.Unlock()
+
+	// csv rows, and xLights models with start channels of their own, carry their own
+	// addresses
+	readdressed := updated.Universe != segment.config.Universe || updated.StartChannel != segment.config.StartChannel
+	if readdressed && sameAddresses((*pc.pixelMap.pixels)[segment.start:segment.start+segment.count], *pixels) {
+		return LayoutReport{}, fmt.Errorf("segment %s takes its universe and channels from %s, so they can't be changed", name, segment.config.Source)
+	}
+
+	// validate a copy before touching the live pixels
+	candidate := make([]Pixel, len(*pc.pixelMap.pixels))
+	copy(candidate, *pc.pixelMap.pixels)
+	copy(candidate[segment.start:], *pixels)
+	candidateMap := &PixelMap{
+		pixels:   &candidate,
+		segments: pc.pixelMap.segments,
+		sections: pc.pixelMap.sections,
+		ddp:      pc.pixelMap.ddp,
+		opc:      pc.pixelMap.opc,
+		wled:     pc.pixelMap.wled,
+	}
+
+	report := validateLayout(candidateMap, pc.activeUniverses())
+	if !report.Valid && !request.Force {
+		return report, ErrInvalidLayout
+	}
+
+	// keep whatever is currently displayed so the edit doesn't flash
+	live := *pc.pixelMap.pixels
+	for i, pixel := range *pixels {
+		pixel.color = live[segment.start+i].color
+		live[segment.start+i] = pixel
+	}
+	pc.pixelMap.segments[index].config = updated
+	pc.pixelMap.applyGroups(segment.start, segment.start+segment.count)
+	pc.pixelMap.computeGeometry()
+	pc.pixelMap.computeSampling()
+	pc.organizePixelsByUniverse(pc.pixelMap)
+	pc.syncZoneLayouts()
+
+	log.Printf("Segment %s updated: universe %d, start channel %d, position (%d, %d), rotation %d",
+		name, updated.Universe, updated.StartChannel, updated.X, updated.Y, updated.Rotation)
+
+	return report, nil
+}
+
+// reports whether every pixel keeps its universe and channel
+func sameAddresses(current []Pixel, rebuilt []Pixel) bool {
+	for i := range current {
+		if current[i].universe != rebuilt[i].universe || current[i].channelPosition != rebuilt[i].channelPosition {
+			return false
+		}
+	}
+	return true
 }
 
 // GetSections returns the sections used for color correction
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+)
+
+// builds a controller for the shipped layout with a DDP device and an OPC server alongside
+// its universes, publishing to slots nothing takes from
+func newTestController(tb testing.TB) *PixelController {
+	tb.Helper()
+
+	layout, err := loadLayout("layout.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	sections := layout.buildSections()
+	pixelMap, err := layout.buildPixelMap(sections)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	pixelMap.ddp = []DDPConfig{{Name: "ddp", Address: "127.0.0.1", Segments: []string{"leftFrontLeg1", "leftFrontLeg2"}}}
+	pixelMap.opc = []OPCConfig{{Name: "opc", Address: "127.0.0.1", Universes: []uint16{1}}}
+
+	universes := make(map[uint16]*FrameSlot)
+	for _, universe := range layout.universeNumbers() {
+		universes[universe] = NewFrameSlot()
+	}
+
+	options := DefaultOptions()
+	options.AddColorCorrectionOptions(sections)
+	patterns := registerPatterns(pixelMap)
+
+	controller := NewPixelController(universes, NewErrorTracker(1e9, 10), 40, patterns["maskOnly"], pixelMap, options.share())
+	controller.SetDevices(map[string]*FrameSlot{"ddp": NewFrameSlot(), "opc": NewFrameSlot()})
+	controller.organizePixelsByUniverse(pixelMap)
+	return controller
+}
+
+func TestUpdateAllUniversesDoesNotAllocate(t *testing.T) {
+	controller := newTestController(t)
+	controller.updateAllUniverses()
+
+	if allocs := testing.AllocsPerRun(100, func() { controller.updateAllUniverses() }); allocs != 0 {
+		t.Errorf("updateAllUniverses allocated %v times per frame", allocs)
+	}
+}
+
+func TestUpdateAllUniversesChangesOnlyDoesNotAllocate(t *testing.T) {
+	controller := newTestController(t)
+	controller.SetChangesOnly(true)
+	controller.updateAllUniverses()
+
+	if allocs := testing.AllocsPerRun(100, func() { controller.updateAllUniverses() }); allocs != 0 {
+		t.Errorf("updateAllUniverses allocated %v times per frame", allocs)
+	}
+}
+
+func TestOPCPatternDoesNotAllocate(t *testing.T) {
+	controller := newTestController(t)
+	pattern := controller.patterns["opc"].(*OPCPattern)
+	pattern.frames = NewOPCFrames()
+	pattern.frames.set(OPCBroadcastChannel, make([]byte, 300))
+	pattern.frames.set(1, make([]byte, 300))
+	pattern.Parameters.Hold.Value = 60
+	controller.currentPattern = pattern
+	controller.updateAllUniverses()
+
+	if allocs := testing.AllocsPerRun(100, func() { controller.updateAllUniverses() }); allocs != 0 {
+		t.Errorf("updateAllUniverses allocated %v times per frame with the opc pattern", allocs)
+	}
+}
+
+func BenchmarkUpdateAllUniverses(b *testing.B) {
+	controller := newTestController(b)
+	controller.updateAllUniverses()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		controller.updateAllUniverses()
+	}
+}
+
+func BenchmarkPrepareUniverseData(b *testing.B) {
+	controller := newTestController(b)
+	correction := controller.colorCorrection()
+	slot := controller.universes[1]
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		slot.Publish(controller.prepareUniverseData(1, correction))
+	}
+}
+
+func BenchmarkPrepareDeviceData(b *testing.B) {
+	controller := newTestController(b)
+	correction := controller.colorCorrection()
+
+	for _, device := range []string{"ddp", "opc"} {
+		b.Run(device, func(b *testing.B) {
+			slot := controller.devices[device]
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				slot.Publish(controller.prepareDeviceData(device, correction))
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// for chasing flicker reports from the field, every universe buffer the controller hands to
+// the outputs can be recorded to a capture file and later replayed through the outputs with
+// no patterns running, so a controller sees exactly what it saw then.
+//
+// a capture starts with CaptureMagic and the unix time recording started in nanoseconds.
+// each frame follows as its offset from the start in nanoseconds and a universe count, then
+// every universe as its number, data length and data. numbers are big endian.
+
+const (
+	CaptureMagic     = "GOLEDZ01"
+	CaptureExtension = ".goledz"
+)
+
+// CaptureFrame is every universe written in one frame
+type CaptureFrame struct {
+	Offset    time.Duration
+	Universes map[uint16][]byte
+}
+
+// FrameRecorder writes frames to a capture file
+type FrameRecorder struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	start  time.Time
+	frames int
+	mu     sync.Mutex
+}
+
+// RecordingInfo describes the recording in progress
+type RecordingInfo struct {
+	File    string    `json:"file"`
+	Started time.Time `json:"started"`
+	Frames  int       `json:"frames"`
+}
+
+func NewFrameRecorder(path string) (*FrameRecorder, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create capture directory: %w", err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+
+	r := &FrameRecorder{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriter(file),
+		start:  time.Now(),
+	}
+
+	header := make([]byte, len(CaptureMagic)+8)
+	copy(header, CaptureMagic)
+	binary.BigEndian.PutUint64(header[len(CaptureMagic):], uint64(r.start.UnixNano()))
+	if _, err := r.writer.Write(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+
+	log.Printf("Recording universes to %s", path)
+	return r, nil
+}
+
+// returns a new capture file name in the directory, named for the current time
+func newCapturePath(dir string) string {
+	return filepath.Join(dir, "capture-"+time.Now().Format("20060102-150405")+CaptureExtension)
+}
+
+// RecordFrame appends the universes written in one frame
+func (r *FrameRecorder) RecordFrame(universes map[uint16][]byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	numbers := make([]uint16, 0, len(universes))
+	for number := range universes {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	header := make([]byte, 10)
+	binary.BigEndian.PutUint64(header, uint64(time.Since(r.start)))
+	binary.BigEndian.PutUint16(header[8:], uint16(len(numbers)))
+	if _, err := r.writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, number := range numbers {
+		data := universes[number]
+		var universeHeader [4]byte
+		binary.BigEndian.PutUint16(universeHeader[0:], number)
+		binary.BigEndian.PutUint16(universeHeader[2:], uint16(len(data)))
+		if _, err := r.writer.Write(universeHeader[:]); err != nil {
+			return err
+		}
+		if _, err := r.writer.Write(data); err != nil {
+			return err
+		}
+	}
+	r.frames++
+	return nil
+}
+
+func (r *FrameRecorder) Info() RecordingInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return RecordingInfo{File: r.path, Started: r.start, Frames: r.frames}
+}
+
+func (r *FrameRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.writer.Flush()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	log.Printf("Recorded %d frames to %s", r.frames, r.path)
+	return err
+}
+
+// StartRecording records every frame sent to the universes to a new capture file
+func (pc *PixelController) StartRecording(path string) (RecordingInfo, error) {
+	pc.recorderMu.Lock()
+	defer pc.recorderMu.Unlock()
+
+	if pc.recorder != nil {
+		return RecordingInfo{}, fmt.Errorf("already recording to %s", pc.recorder.path)
+	}
+	recorder, err := NewFrameRecorder(path)
+	if err != nil {
+		return RecordingInfo{}, err
+	}
+	pc.recorder = recorder
+	return recorder.Info(), nil
+}
+
+// StopRecording finishes the capture file, returning what was recorded
+func (pc *PixelController) StopRecording() (RecordingInfo, error) {
+	pc.recorderMu.Lock()
+	defer pc.recorderMu.Unlock()
+
+	if pc.recorder == nil {
+		return RecordingInfo{}, fmt.Errorf("not recording")
+	}
+	info := pc.recorder.Info()
+	err := pc.recorder.Close()
+	pc.recorder = nil
+	return info, err
+}
+
+// Recording describes the recording in progress, if there is one
+func (pc *PixelController) Recording() (RecordingInfo, bool) {
+	pc.recorderMu.Lock()
+	defer pc.recorderMu.Unlock()
+
+	if pc.recorder == nil {
+		return RecordingInfo{}, false
+	}
+	return pc.recorder.Info(), true
+}
+
+func (pc *PixelController) recordFrame(frame map[uint16][]byte) {
+	pc.recorderMu.Lock()
+	defer pc.recorderMu.Unlock()
+
+	if pc.recorder == nil {
+		return
+	}
+	// a failing disk stops the recording rather than the show
+	if err := pc.recorder.RecordFrame(frame); err != nil {
+		log.Printf("Error recording frame, stopping the recording: %v", err)
+		pc.recorder.Close()
+		pc.recorder = nil
+	}
+}
+
+// CaptureReader reads the frames of a capture file in order
+type CaptureReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	Start  time.Time
+}
+
+func OpenCapture(path string) (*CaptureReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, len(CaptureMagic)+8)
+	if _, err := io.ReadFull(reader, header); err != nil || !bytes.Equal(header[:len(CaptureMagic)], []byte(CaptureMagic)) {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a capture file", path)
+	}
+
+	return &CaptureReader{
+		file:   file,
+		reader: reader,
+		Start:  time.Unix(0, int64(binary.BigEndian.Uint64(header[len(CaptureMagic):]))),
+	}, nil
+}
+
+// Next returns the next frame, or io.EOF after the last one
+func (c *CaptureReader) Next() (CaptureFrame, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return CaptureFrame{}, fmt.Errorf("capture ends mid frame: %w", err)
+		}
+		return CaptureFrame{}, err
+	}
+
+	frame := CaptureFrame{
+		Offset:    time.Duration(binary.BigEndian.Uint64(header)),
+		Universes: make(map[uint16][]byte),
+	}
+	for range binary.BigEndian.Uint16(header[8:]) {
+		var universeHeader [4]byte
+		if _, err := io.ReadFull(c.reader, universeHeader[:]); err != nil {
+			return CaptureFrame{}, fmt.Errorf("capture ends mid frame: %w", err)
+		}
+		data := make([]byte, binary.BigEndian.Uint16(universeHeader[2:]))
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return CaptureFrame{}, fmt.Errorf("capture ends mid frame: %w", err)
+		}
+		frame.Universes[binary.BigEndian.Uint16(universeHeader[0:])] = data
+	}
+	return frame, nil
+}
+
+func (c *CaptureReader) Close() error {
+	return c.file.Close()
+}
+
+// Replayer streams a capture back through the outputs, at its original timing or a frame at
+// a time
+type Replayer struct {
+	path      string
+	universes map[uint16]*FrameSlot
+	sync      func() error
+	loop      bool
+	skipped   map[uint16]bool
+}
+
+func NewReplayer(path string, universes map[uint16]*FrameSlot, sync func() error, loop bool) *Replayer {
+	return &Replayer{
+		path:      path,
+		universes: universes,
+		sync:      sync,
+		loop:      loop,
+		skipped:   make(map[uint16]bool),
+	}
+}
+
+// returned by play when the replay is stopped before the end of the capture
+var errReplayStopped = NewError("replay stopped")
+
+// Run plays the capture at its original timing, calling wait before each frame when it's
+// set instead. it returns once the capture has played, when wait returns false or done is
+// closed, or, when looping, only then.
+func (r *Replayer) Run(wait func(frame int, offset time.Duration) bool, done <-chan struct{}) error {
+	for {
+		err := r.play(wait, done)
+		if errors.Is(err, errReplayStopped) {
+			return nil
+		}
+		if err != nil || !r.loop {
+			return err
+		}
+	}
+}
+
+func (r *Replayer) play(wait func(frame int, offset time.Duration) bool, done <-chan struct{}) error {
+	capture, err := OpenCapture(r.path)
+	if err != nil {
+		return err
+	}
+	defer capture.Close()
+
+	log.Printf("Replaying %s, recorded %s", r.path, capture.Start.Format(time.RFC3339))
+	start := time.Now()
+	for count := 0; ; count++ {
+		frame, err := capture.Next()
+		if errors.Is(err, io.EOF) {
+			if count == 0 {
+				// nothing to play, and looping would just open it again and again
+				return fmt.Errorf("capture %s has no frames", r.path)
+			}
+			log.Printf("Replayed %d frames", count)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if wait != nil {
+			if !wait(count, frame.Offset) {
+				return errReplayStopped
+			}
+		} else {
+			select {
+			case <-time.After(time.Until(start.Add(frame.Offset))):
+			case <-done:
+				return errReplayStopped
+			}
+		}
+
+		if err := r.send(frame); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Replayer) send(frame CaptureFrame) error {
+	for number, data := range frame.Universes {
+		slot, exists := r.universes[number]
+		if !exists {
+			if !r.skipped[number] {
+				log.Printf("Warning: universe %d is in the capture but has no output, skipping it", number)
+				r.skipped[number] = true
+			}
+			continue
+		}
+		slot.Publish(data)
+	}
+	if r.sync != nil {
+		return r.sync()
+	}
+	return nil
+}
+
+// runs replay mode until the capture has played or we're interrupted, then closes the
+// outputs. stepping waits for enter on stdin before each frame.
+func runReplay(path string, step bool, loop bool, outputs *OutputHandler) error {
+	done := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		close(done)
+	}()
+
+	var wait func(frame int, offset time.Duration) bool
+	if step {
+		// stdin is read on its own goroutine so an interrupt doesn't wait for enter
+		lines := make(chan struct{})
+		go func() {
+			defer close(lines)
+			input := bufio.NewScanner(os.Stdin)
+			for input.Scan() {
+				select {
+				case lines <- struct{}{}:
+				case <-done:
+					return
+				}
+			}
+		}()
+		wait = func(frame int, offset time.Duration) bool {
+			fmt.Printf("Frame %d at %v, press enter to send it\n", frame, offset)
+			select {
+			case _, ok := <-lines:
+				return ok
+			case <-done:
+				return false
+			}
+		}
+	}
+
+	replayer := NewReplayer(path, outputs.GetUniverses(), outputs.SyncUniverses, loop)
+	err := replayer.Run(wait, done)
+	if closeErr := outputs.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
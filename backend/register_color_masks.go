@@ -1,8 +1,9 @@
 package main
 
-func registerColorMasks() map[string]ColorMaskPattern {
+func registerColorMasks(pixelMap *PixelMap) map[string]ColorMaskPattern {
 	masks := make(map[string]ColorMaskPattern)
 	gradientMask := GradientColorMask{
+		pixelMap: pixelMap,
 		BasePattern: BasePattern{
 			Label: "Gradient",
 		},
@@ -85,6 +86,7 @@ func registerColorMasks() map[string]ColorMaskPattern {
 		},
 	}
 	rainbowCircleMask := RainbowCircleMask{
+		pixelMap: pixelMap,
 		BasePattern: BasePattern{
 			Label: "Rainbow Circle",
 		},
@@ -108,6 +110,7 @@ func registerColorMasks() map[string]ColorMaskPattern {
 		},
 	}
 	rainbowPinwheelMask := RainbowPinwheelMask{
+		pixelMap: pixelMap,
 		BasePattern: BasePattern{
 			Label: "Rainbow Pinwheel",
 		},
@@ -132,6 +135,7 @@ func registerColorMasks() map[string]ColorMaskPattern {
 	}
 
 	waveMask := WaveColorMask{
+		pixelMap: pixelMap,
 		BasePattern: BasePattern{
 			Label: "Wave Interference",
 		},
@@ -184,6 +188,7 @@ func registerColorMasks() map[string]ColorMaskPattern {
 	}
 
 	kaleidoscopeMask := KaleidoscopeColorMask{
+		pixelMap: pixelMap,
 		BasePattern: BasePattern{
 			Label: "Kaleidoscope",
 		},
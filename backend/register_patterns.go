@@ -113,6 +113,12 @@ func registerPatterns(pixelMap *PixelMap) map[string]Pattern {
 				Value: 30.0,
 				Type:  TYPE_FLOAT,
 			},
+			Twist: FloatParameter{
+				Min:   floatPointer(-5.0),
+				Max:   5.0,
+				Value: 1.0,
+				Type:  TYPE_FLOAT,
+			},
 		},
 	}
 	stripesPattern := StripesPattern{
@@ -178,6 +184,86 @@ func registerPatterns(pixelMap *PixelMap) map[string]Pattern {
 			},
 		},
 	}
+	cometPattern := CometPattern{
+		BasePattern: BasePattern{
+			Label: "Comet",
+		},
+		pixelMap: pixelMap,
+		Parameters: CometParameters{
+			Speed: FloatParameter{
+				Min:   floatPointer(0.1),
+				Max:   5.0,
+				Value: 0.5,
+				Type:  TYPE_FLOAT,
+			},
+			TailLength: IntParameter{
+				Min:   intPointer(1),
+				Max:   100,
+				Value: 12,
+				Type:  TYPE_INT,
+			},
+			Count: IntParameter{
+				Min:   intPointer(1),
+				Max:   10,
+				Value: 1,
+				Type:  TYPE_INT,
+			},
+			Reversed: BooleanParameter{
+				Value: false,
+				Type:  TYPE_BOOL,
+			},
+		},
+	}
+	opcPattern := OPCPattern{
+		BasePattern: BasePattern{
+			Label: "Open Pixel Control",
+		},
+		pixelMap: pixelMap,
+		frames:   opcFrames,
+		Parameters: OPCParameters{
+			Hold: FloatParameter{
+				Min:   floatPointer(0.0),
+				Max:   60.0,
+				Value: 5.0,
+				Type:  TYPE_FLOAT,
+			},
+		},
+	}
+
+	meteorPattern := MeteorPattern{
+		BasePattern: BasePattern{
+			Label: "Meteor",
+		},
+		pixelMap: pixelMap,
+		Parameters: MeteorParameters{
+			Speed: FloatParameter{
+				Min:   floatPointer(0.1),
+				Max:   5.0,
+				Value: 0.5,
+				Type:  TYPE_FLOAT,
+			},
+			Size: IntParameter{
+				Min:   intPointer(1),
+				Max:   20,
+				Value: 3,
+				Type:  TYPE_INT,
+			},
+			Decay: FloatParameter{
+				Min:   floatPointer(1.0),
+				Max:   50.0,
+				Value: 15.0,
+				Type:  TYPE_FLOAT,
+			},
+			RandomDecay: BooleanParameter{
+				Value: true,
+				Type:  TYPE_BOOL,
+			},
+			Reversed: BooleanParameter{
+				Value: false,
+				Type:  TYPE_BOOL,
+			},
+		},
+	}
 
 	randomPattern := RandomPattern{
 		BasePattern: BasePattern{
@@ -470,6 +556,8 @@ func registerPatterns(pixelMap *PixelMap) map[string]Pattern {
 	patterns[lightsOffPattern.GetName()] = &lightsOffPattern
 	patterns[stripesPattern.GetName()] = &stripesPattern
 	patterns[chaserPattern.GetName()] = &chaserPattern
+	patterns[cometPattern.GetName()] = &cometPattern
+	patterns[meteorPattern.GetName()] = &meteorPattern
 	patterns[pulsePattern.GetName()] = &pulsePattern
 	patterns[spiralPattern.GetName()] = &spiralPattern
 	patterns[sparklePattern.GetName()] = &sparklePattern
@@ -477,6 +565,7 @@ func registerPatterns(pixelMap *PixelMap) map[string]Pattern {
 	patterns[matrixPattern.GetName()] = &matrixPattern
 	patterns[firePattern.GetName()] = &firePattern
 	patterns[plasmaPattern.GetName()] = &plasmaPattern
+	patterns[opcPattern.GetName()] = &opcPattern
 	// patterns[particlesPattern.GestName()] = &particlesPattern
 	// patterns[audioReactivePattern.GetName()] = &audioReactivePattern
 
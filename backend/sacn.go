@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,20 +17,33 @@ const (
 	RootACNPacketID   = "ASC-E1.17"
 	RootVector        = uint32(0x00000004)
 	FramingVector     = uint32(0x00000002)
+	ExtendedVector    = uint32(0x00000008)
+	SyncVector        = uint32(0x00000001)
 	DMPVector         = uint8(0x02)
 	DefaultPriority   = uint8(100)
+	MaxPriority       = uint8(200)
 	DefaultStartCode  = uint8(0x00)
 	UniverseChannels  = 512
 	KeepAliveInterval = time.Second
 
 	// Packet options
 	StreamTerminateOptionsBit = 6
+	StreamTerminatePackets    = 3 // E1.31 has sources send three terminated packets when they stop
+
+	// Universe discovery
+	DiscoveryUniverse        = uint16(64214)
+	DiscoveryVector          = uint32(0x00000002)
+	DiscoveryListVector      = uint32(0x00000001)
+	DiscoveryInterval        = 10 * time.Second
+	DiscoveryHeaderLength    = 120
+	DiscoveryUniversesByPage = 512
 
 	// UDP
 	SACNPort        = 5568
 	MaxPacketSize   = 638
 	HeaderLength    = 126
 	RootLayerLength = 38
+	SyncPacketSize  = 49
 )
 
 type Destination struct {
@@ -38,33 +52,53 @@ type Destination struct {
 }
 
 type Transmitter struct {
-	conn       *net.UDPConn
-	universes  map[uint16]*Universe
-	cid        [16]byte
-	sourceName string
-	mu         sync.RWMutex
-	done       chan struct{}
-	wg         sync.WaitGroup
+	conn         *net.UDPConn
+	universes    map[uint16]*Universe
+	cid          [16]byte
+	sourceName   string
+	syncSequence uint8
+	syncTargets  map[uint16][]*net.UDPAddr // where each sync universe's packets go, nil when they need working out again
+	syncPacket   []byte
+	multicastIf  string // interface name or address multicast goes out on
+	multicastTTL int
+	multicastSet bool // the multicast socket options are only set once something is multicast
+	mu           sync.RWMutex
+	done         chan struct{}
+	wg           sync.WaitGroup
 }
 
 type Universe struct {
 	number       uint16
 	sequence     uint8
 	destinations []Destination
-	data         []byte
-	dataChan     chan []byte
+	packet       []byte // written in place for every send, the header only once
+	data         []byte // the DMX data, in the packet
+	slot         *FrameSlot
 	lastSent     time.Time
 	priority     uint8
+	options      uint8
+
+	// universe whose sync packets tell receivers to show this universe's data, 0 when
+	// the data is shown as soon as it arrives
+	syncAddress uint16
 }
 
 type TransmitterConfig struct {
 	CID        [16]byte
 	SourceName string
 	Priority   uint8
+
+	// interface name or address multicast is sent from, and its TTL
+	MulticastInterface string
+	MulticastTTL       int
+
+	// periodically lists our universes on the discovery universe for monitoring tools
+	Discovery bool
 }
 
 func NewTransmitter(config TransmitterConfig) (*Transmitter, error) {
-	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	// sACN is IPv4, and the multicast socket options are IPv4 ones
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create UDP connection: %w", err)
 	}
@@ -76,13 +110,35 @@ func NewTransmitter(config TransmitterConfig) (*Transmitter, error) {
 		sourceName: config.SourceName,
 		done:       make(chan struct{}),
 	}
+	t.multicastIf = config.MulticastInterface
+	t.multicastTTL = config.MulticastTTL
+	if t.multicastTTL <= 0 {
+		t.multicastTTL = DefaultMulticastTTL
+	}
+
+	// universe discovery is multicast
+	if config.Discovery {
+		if err := t.configureMulticast(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
 
 	t.wg.Add(1)
 	go t.keepAliveLoop()
 
+	if config.Discovery {
+		t.wg.Add(1)
+		go t.discoveryLoop()
+	}
+
 	return t, nil
 }
 
+func (t *Transmitter) Protocol() string {
+	return PROTOCOL_SACN
+}
+
 func calculateFlagsAndLength(length uint16) []byte {
 	// Mask length to 12 bits and set high nibble to 0x7
 	value := uint16(0x7000) | (length & 0x0FFF)
@@ -94,8 +150,9 @@ func calculateFlagsAndLength(length uint16) []byte {
 	}
 }
 
-func (t *Transmitter) createPacket(universe *Universe) []byte {
-	packet := make([]byte, MaxPacketSize)
+// writes the parts of a universe's packet that never change, once when it's activated
+func (t *Transmitter) writePacketHeader(universe *Universe) {
+	packet := universe.packet
 
 	// Root Layer Preamble (bytes 0-15)
 	binary.BigEndian.PutUint16(packet[0:], 0x0010)      // Preamble Size
@@ -103,43 +160,123 @@ func (t *Transmitter) createPacket(universe *Universe) []byte {
 	copy(packet[4:16], []byte("ASC-E1.17\000\000\000")) // ACN Packet Identifier
 
 	// Root Layer PDU (bytes 16-37)
-	flagsLength := calculateFlagsAndLength(uint16(len(universe.data) + 110))
-	copy(packet[16:18], flagsLength)                      // Flags and Length
 	binary.BigEndian.PutUint32(packet[18:22], 0x00000004) // Root Vector
 	copy(packet[22:38], t.cid[:])                         // CID
 
 	// Framing Layer PDU
-	framingFlagsLength := calculateFlagsAndLength(uint16(len(universe.data) + 88))
-	copy(packet[38:40], framingFlagsLength)               // Flags and Length
 	binary.BigEndian.PutUint32(packet[40:44], 0x00000002) // Framing Vector
 	copy(packet[44:108], padString(t.sourceName, 64))     // Source Name
 
-	packet[108] = universe.priority                     // Priority
-	binary.BigEndian.PutUint16(packet[109:111], 0x0000) // Sync Address (0 for non-synchronized)
-	packet[111] = universe.sequence                     // Sequence Number
-	packet[112] = 0x00                                  // Options Flags
-	packet[113] = byte(universe.number >> 8)            // High byte of universe number
-	packet[114] = byte(universe.number & 0xFF)          // Low byte of universe number
+	binary.BigEndian.PutUint16(packet[109:111], universe.syncAddress) // Sync Address (0 for non-synchronized)
+	packet[113] = byte(universe.number >> 8)                          // High byte of universe number
+	packet[114] = byte(universe.number & 0xFF)                        // Low byte of universe number
 
 	// DMP Layer
-	packet[115] = 0x72      // DMP Layer flags and length
-	packet[116] = 0x0b      // DMP Layer length low byte
-	packet[117] = DMPVector // DMP Vector (0x02)
-	packet[118] = 0xa1      // DMP Address & Data Type
-	packet[119] = 0x00      // First Property Address
-	packet[120] = 0x00      // First Property Address
-	packet[121] = 0x00      // Address Increment
-	packet[122] = 0x01      // Address Increment
-	binary.BigEndian.PutUint16(packet[123:125], uint16(len(universe.data)+1))
+	packet[117] = DMPVector        // DMP Vector (0x02)
+	packet[118] = 0xa1             // DMP Address & Data Type
+	packet[119] = 0x00             // First Property Address
+	packet[120] = 0x00             // First Property Address
+	packet[121] = 0x00             // Address Increment
+	packet[122] = 0x01             // Address Increment
 	packet[125] = DefaultStartCode // DMX Start Code (0x00)
+}
+
+// fills in the rest of the universe's packet around the data already in it
+func (t *Transmitter) createPacket(universe *Universe) []byte {
+	packet := universe.packet
+	length := uint16(len(universe.data))
+
+	binary.BigEndian.PutUint16(packet[16:18], 0x7000|(length+110)&0x0FFF)  // Root Flags and Length
+	binary.BigEndian.PutUint16(packet[38:40], 0x7000|(length+88)&0x0FFF)   // Framing Flags and Length
+	packet[108] = universe.priority                                        // Priority
+	packet[111] = universe.sequence                                        // Sequence Number
+	packet[112] = universe.options                                         // Options Flags
+	binary.BigEndian.PutUint16(packet[115:117], 0x7000|(length+11)&0x0FFF) // DMP Flags and Length
+	binary.BigEndian.PutUint16(packet[123:125], length+1)                  // Property Value Count
+
+	return packet[:HeaderLength+len(universe.data)]
+}
 
-	// DMX Data
-	copy(packet[126:], universe.data)
+// copies a frame into the universe's packet, leaving the buffer free for the controller to
+// write its next frame to
+func (u *Universe) setData(data []byte) {
+	if len(data) > UniverseChannels {
+		log.Printf("Invalid DMX data length for universe %d: %d", u.number, len(data))
+		return
+	}
+	u.data = u.packet[HeaderLength : HeaderLength+copy(u.packet[HeaderLength:], data)]
+}
+
+// creates an E1.31 synchronization packet, which has no data of its own
+func (t *Transmitter) createSyncPacket(syncAddress uint16) []byte {
+	if t.syncPacket == nil {
+		t.syncPacket = make([]byte, SyncPacketSize)
+	}
+	packet := t.syncPacket
+
+	// Root Layer, identical to a data packet's apart from the vector
+	binary.BigEndian.PutUint16(packet[0:], RootPreambleSize)
+	binary.BigEndian.PutUint16(packet[2:], RootPostambleSize)
+	copy(packet[4:16], []byte("ASC-E1.17\000\000\000"))
+	copy(packet[16:18], calculateFlagsAndLength(SyncPacketSize-16))
+	binary.BigEndian.PutUint32(packet[18:22], ExtendedVector)
+	copy(packet[22:38], t.cid[:])
+
+	// Synchronization Framing Layer
+	copy(packet[38:40], calculateFlagsAndLength(SyncPacketSize-38))
+	binary.BigEndian.PutUint32(packet[40:44], SyncVector)
+	packet[44] = t.syncSequence
+	binary.BigEndian.PutUint16(packet[45:47], syncAddress)
+	// bytes 47-48 are reserved
+
+	return packet
+}
+
+// creates one page of an E1.31 universe discovery packet, listing universes in order
+func (t *Transmitter) createDiscoveryPacket(universes []uint16, page uint8, lastPage uint8) []byte {
+	length := DiscoveryHeaderLength + 2*len(universes)
+	packet := make([]byte, length)
+
+	// Root Layer
+	binary.BigEndian.PutUint16(packet[0:], RootPreambleSize)
+	binary.BigEndian.PutUint16(packet[2:], RootPostambleSize)
+	copy(packet[4:16], []byte("ASC-E1.17\000\000\000"))
+	copy(packet[16:18], calculateFlagsAndLength(uint16(length-16)))
+	binary.BigEndian.PutUint32(packet[18:22], ExtendedVector)
+	copy(packet[22:38], t.cid[:])
+
+	// Discovery Framing Layer
+	copy(packet[38:40], calculateFlagsAndLength(uint16(length-38)))
+	binary.BigEndian.PutUint32(packet[40:44], DiscoveryVector)
+	copy(packet[44:108], padString(t.sourceName, 64))
+	// bytes 108-111 are reserved
+
+	// Universe Discovery Layer
+	copy(packet[112:114], calculateFlagsAndLength(uint16(length-112)))
+	binary.BigEndian.PutUint32(packet[114:118], DiscoveryListVector)
+	packet[118] = page
+	packet[119] = lastPage
+	for i, universe := range universes {
+		binary.BigEndian.PutUint16(packet[DiscoveryHeaderLength+2*i:], universe)
+	}
+
+	return packet
+}
 
-	return packet[:126+len(universe.data)]
+func (t *Transmitter) Activate(universeNumber uint16) (*FrameSlot, error) {
+	return t.activate(universeNumber, 0)
 }
 
-func (t *Transmitter) Activate(universeNumber uint16) (chan<- []byte, error) {
+// ActivateSynchronized starts sending a universe whose data is only shown when a sync packet
+// for the sync universe arrives. its data is sent by Sync rather than as soon as it's written.
+func (t *Transmitter) ActivateSynchronized(universeNumber uint16, syncUniverse uint16) (*FrameSlot, error) {
+	if syncUniverse == 0 {
+		return nil, fmt.Errorf("universe %d: sync universe can't be 0", universeNumber)
+	}
+	return t.activate(universeNumber, syncUniverse)
+}
+
+func (t *Transmitter) activate(universeNumber uint16, syncAddress uint16) (*FrameSlot, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -147,25 +284,32 @@ func (t *Transmitter) Activate(universeNumber uint16) (chan<- []byte, error) {
 		return nil, fmt.Errorf("universe %d already activated", universeNumber)
 	}
 
+	packet := make([]byte, MaxPacketSize)
 	universe := &Universe{
 		number:       universeNumber,
 		sequence:     0,
 		destinations: make([]Destination, 0),
-		data:         make([]byte, UniverseChannels),
-		dataChan:     make(chan []byte, 100),
+		packet:       packet,
+		data:         packet[HeaderLength:],
+		slot:         NewFrameSlot(),
 		priority:     DefaultPriority,
+		syncAddress:  syncAddress,
 	}
+	t.writePacketHeader(universe)
 
 	t.universes[universeNumber] = universe
+	t.syncTargets = nil
 
-	t.wg.Add(1)
-	go t.handleUniverse(universe)
+	if syncAddress == 0 {
+		t.wg.Add(1)
+		go t.handleUniverse(universe)
+	}
 
-	return universe.dataChan, nil
+	return universe.slot, nil
 }
 
 func (t *Transmitter) SetDestination(universe uint16, addr string, priority uint8) error {
-	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", addr, SACNPort))
+	udpAddr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", addr, SACNPort))
 	if err != nil {
 		return fmt.Errorf("invalid address %s: %w", addr, err)
 	}
@@ -176,25 +320,175 @@ func (t *Transmitter) SetDestination(universe uint16, addr string, priority uint
 	if !exists {
 		return fmt.Errorf("universe %d not activated", universe)
 	}
+	if udpAddr.IP.IsMulticast() {
+		if err := t.configureMulticast(); err != nil {
+			return err
+		}
+	}
 
-	state.destinations = []Destination{{
+	// replaces the unicast destination, leaving the multicast group in place
+	destinations := []Destination{{
 		Addr:     udpAddr,
 		Priority: priority,
 	}}
+	for _, dest := range state.destinations {
+		if dest.Addr.IP.IsMulticast() {
+			destinations = append(destinations, dest)
+		}
+	}
+	state.destinations = destinations
+	t.syncTargets = nil
 
 	return nil
 }
 
+// AddDestination sends the universe to another receiver as well, e.g. a monitoring PC
+// alongside the controller
+func (t *Transmitter) AddDestination(universe uint16, addr string, priority uint8) error {
+	udpAddr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", addr, SACNPort))
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, exists := t.universes[universe]
+	if !exists {
+		return fmt.Errorf("universe %d not activated", universe)
+	}
+	if udpAddr.IP.IsMulticast() {
+		if err := t.configureMulticast(); err != nil {
+			return err
+		}
+	}
+
+	state.destinations = append(state.destinations, Destination{
+		Addr:     udpAddr,
+		Priority: priority,
+	})
+	t.syncTargets = nil
+
+	return nil
+}
+
+// sets the interface and TTL multicast goes out with, the first time anything is multicast,
+// so unicast-only setups never depend on the multicast socket options
+func (t *Transmitter) configureMulticast() error {
+	if t.multicastSet {
+		return nil
+	}
+	if err := configureMulticast(t.conn, t.multicastIf, t.multicastTTL); err != nil {
+		return err
+	}
+	t.multicastSet = true
+	return nil
+}
+
+// AddMulticast sends the universe to its multicast group as well as any unicast destination
+func (t *Transmitter) AddMulticast(universe uint16, priority uint8) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, exists := t.universes[universe]
+	if !exists {
+		return fmt.Errorf("universe %d not activated", universe)
+	}
+	if err := t.configureMulticast(); err != nil {
+		return err
+	}
+
+	state.destinations = append(state.destinations, Destination{
+		Addr:     multicastAddress(universe),
+		Priority: priority,
+	})
+	t.syncTargets = nil
+
+	return nil
+}
+
+// Sync sends the latest data written to each synchronized universe, then a sync packet so
+// receivers show all of it at once. it's called once per frame, after every universe has
+// been written, and sends nothing when none of them have new data.
+func (t *Transmitter) Sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	synced := false
+	for _, universe := range t.universes {
+		if universe.syncAddress == 0 {
+			continue
+		}
+
+		if universe.slot.Take(universe.setData) {
+			universe.lastSent = time.Now()
+			t.sendToDestinations(universe)
+			synced = true
+		}
+	}
+
+	if synced {
+		t.sendSyncPackets()
+	}
+	return nil
+}
+
+// sends a sync packet for each sync universe in use, to every receiver of the universes it
+// synchronizes, and to its multicast group when any of them are multicast
+func (t *Transmitter) sendSyncPackets() {
+	if t.syncTargets == nil {
+		t.syncTargets = t.findSyncTargets()
+	}
+
+	t.syncSequence++
+	for syncAddress, addrs := range t.syncTargets {
+		packet := t.createSyncPacket(syncAddress)
+		for _, addr := range addrs {
+			if _, err := t.conn.WriteToUDP(packet, addr); err != nil {
+				log.Printf("Error sending sync for universe %d: %v", syncAddress, err)
+			}
+		}
+	}
+}
+
+// works out where each sync universe's packets go, once for every change of destinations
+func (t *Transmitter) findSyncTargets() map[uint16][]*net.UDPAddr {
+	targets := make(map[uint16]map[string]*net.UDPAddr)
+	for _, universe := range t.universes {
+		if universe.syncAddress == 0 {
+			continue
+		}
+		if targets[universe.syncAddress] == nil {
+			targets[universe.syncAddress] = make(map[string]*net.UDPAddr)
+		}
+		for _, dest := range universe.destinations {
+			addr := dest.Addr
+			if addr.IP.IsMulticast() {
+				addr = multicastAddress(universe.syncAddress)
+			}
+			targets[universe.syncAddress][addr.String()] = addr
+		}
+	}
+
+	syncTargets := make(map[uint16][]*net.UDPAddr, len(targets))
+	for syncAddress, addrs := range targets {
+		for _, addr := range addrs {
+			syncTargets[syncAddress] = append(syncTargets[syncAddress], addr)
+		}
+	}
+	return syncTargets
+}
+
 func (t *Transmitter) handleUniverse(universe *Universe) {
 	defer t.wg.Done()
 
 	for {
 		select {
-		case data := <-universe.dataChan:
+		case <-universe.slot.Ready():
 			t.mu.Lock()
-			universe.data = data
-			universe.lastSent = time.Now()
-			t.sendToDestinations(universe)
+			if universe.slot.Take(universe.setData) {
+				universe.lastSent = time.Now()
+				t.sendToDestinations(universe)
+			}
 			t.mu.Unlock()
 		case <-t.done:
 			// TODO: deactivate the universe?
@@ -215,6 +509,8 @@ func (t *Transmitter) sendToDestinations(universe *Universe) {
 	packet := t.createPacket(universe)
 
 	for _, dest := range universe.destinations {
+		// each destination can be sent at its own priority
+		packet[108] = dest.Priority
 		if _, err := t.conn.WriteToUDP(packet, dest.Addr); err != nil {
 			log.Printf("Error sending to universe %d: %v", universe.number, err)
 			continue
@@ -233,11 +529,17 @@ func (t *Transmitter) keepAliveLoop() {
 		case <-ticker.C:
 			t.mu.Lock()
 			now := time.Now()
+			resynced := false
 			for _, universe := range t.universes {
 				if now.Sub(universe.lastSent) >= KeepAliveInterval {
 					t.sendToDestinations(universe)
+					resynced = resynced || universe.syncAddress != 0
 				}
 			}
+			// receivers hold synchronized data until they're told to show it
+			if resynced {
+				t.sendSyncPackets()
+			}
 			t.mu.Unlock()
 		case <-t.done:
 			return
@@ -245,6 +547,59 @@ func (t *Transmitter) keepAliveLoop() {
 	}
 }
 
+// tells receivers each universe has stopped, so they release it straight away rather than
+// holding the last frame until their own timeout
+func (t *Transmitter) terminateStreams() {
+	for _, universe := range t.universes {
+		universe.options |= 1 << StreamTerminateOptionsBit
+		for range StreamTerminatePackets {
+			t.sendToDestinations(universe)
+		}
+	}
+}
+
+// announces every active universe on the discovery universe, split into pages of 512
+func (t *Transmitter) sendDiscovery() {
+	t.mu.RLock()
+	universes := make([]uint16, 0, len(t.universes))
+	for number := range t.universes {
+		universes = append(universes, number)
+	}
+	t.mu.RUnlock()
+
+	if len(universes) == 0 {
+		return
+	}
+	sort.Slice(universes, func(i, j int) bool { return universes[i] < universes[j] })
+
+	lastPage := (len(universes) - 1) / DiscoveryUniversesByPage
+	addr := multicastAddress(DiscoveryUniverse)
+	for page := 0; page <= lastPage; page++ {
+		pageUniverses := universes[page*DiscoveryUniversesByPage : min((page+1)*DiscoveryUniversesByPage, len(universes))]
+		packet := t.createDiscoveryPacket(pageUniverses, uint8(page), uint8(lastPage))
+		if _, err := t.conn.WriteToUDP(packet, addr); err != nil {
+			log.Printf("Error sending universe discovery: %v", err)
+			return
+		}
+	}
+}
+
+func (t *Transmitter) discoveryLoop() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(DiscoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.sendDiscovery()
+		case <-t.done:
+			return
+		}
+	}
+}
+
 func (t *Transmitter) Close() error {
 	close(t.done)
 	t.wg.Wait()
@@ -252,9 +607,7 @@ func (t *Transmitter) Close() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	for _, state := range t.universes {
-		close(state.dataChan)
-	}
+	t.terminateStreams()
 
 	return t.conn.Close()
 }
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GoLEDz can also receive E1.31, from a lighting console or another instance, and merge it
+// into what it's about to send. the merge happens per universe as each frame is written, so
+// a console operator can take over a section live while everything else keeps running.
+
+const (
+	MERGE_HTP      = "htp"      // highest takes precedence, channel by channel
+	MERGE_LTP      = "ltp"      // latest takes precedence, the last channel to change wins
+	MERGE_PRIORITY = "priority" // the highest sACN priority wins, equal priorities merge HTP
+
+	SourceTimeout    = 2500 * time.Millisecond // E1.31 network data loss timeout
+	PreviewOptionBit = 7
+)
+
+var mergePolicies = []string{MERGE_HTP, MERGE_LTP, MERGE_PRIORITY}
+
+type SACNReceiverConfig struct {
+	Address            string   // address to listen on, port 5568 by default
+	Policy             string   // one of the merge policies
+	MulticastInterface string   // interface multicast groups are joined on
+	IgnoreCID          [16]byte // our own CID, so our multicast isn't merged back in
+}
+
+type SACNReceiver struct {
+	conn      *net.UDPConn
+	policy    string
+	ignoreCID [16]byte
+	iface     [4]byte
+	universes map[uint16]*inputUniverse
+	handlers  map[uint16]func(data []byte)
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// the sources sending a universe, along with what we last generated for it
+type inputUniverse struct {
+	number       uint16
+	priority     uint8
+	sources      map[[16]byte]*inputSource
+	active       []*inputSource // the sources merged into the current frame
+	local        [UniverseChannels]byte
+	localChanged [UniverseChannels]time.Time
+}
+
+type inputSource struct {
+	cid          [16]byte
+	name         string
+	address      string
+	priority     uint8
+	sequence     uint8
+	data         [UniverseChannels]byte
+	length       int
+	changed      [UniverseChannels]time.Time
+	lastReceived time.Time
+}
+
+// InputSourceInfo describes a source currently sending one of our universes
+type InputSourceInfo struct {
+	Universe     uint16    `json:"universe"`
+	Name         string    `json:"name"`
+	Address      string    `json:"address"`
+	Priority     uint8     `json:"priority"`
+	Channels     int       `json:"channels"`
+	LastReceived time.Time `json:"lastReceived"`
+}
+
+func NewSACNReceiver(config SACNReceiverConfig) (*SACNReceiver, error) {
+	policy := config.Policy
+	if policy == "" {
+		policy = MERGE_HTP
+	}
+	known := false
+	for _, p := range mergePolicies {
+		known = known || p == policy
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown merge policy %q", policy)
+	}
+
+	iface, err := multicastInterfaceAddress(config.MulticastInterface)
+	if err != nil {
+		return nil, err
+	}
+
+	address := config.Address
+	if address == "" {
+		address = fmt.Sprintf(":%d", SACNPort)
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sacn input address %s: %w", address, err)
+	}
+	conn, err := net.ListenUDP("udp4", udpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for sacn on %s: %w", address, err)
+	}
+
+	r := &SACNReceiver{
+		conn:      conn,
+		policy:    policy,
+		ignoreCID: config.IgnoreCID,
+		iface:     iface,
+		universes: make(map[uint16]*inputUniverse),
+		handlers:  make(map[uint16]func(data []byte)),
+	}
+
+	r.wg.Add(1)
+	go r.receiveLoop()
+
+	log.Printf("sACN input listening on %s, merging %s", conn.LocalAddr(), policy)
+	return r, nil
+}
+
+// Listen accepts input for the universe, whose generated output is sent at the given
+// priority. the universe's multicast group is joined as well, consoles usually multicast.
+func (r *SACNReceiver) Listen(universe uint16, priority uint8) error {
+	r.mu.Lock()
+	if _, exists := r.universes[universe]; exists {
+		r.mu.Unlock()
+		return fmt.Errorf("already listening to universe %d", universe)
+	}
+	r.universes[universe] = &inputUniverse{
+		number:   universe,
+		priority: priority,
+		sources:  make(map[[16]byte]*inputSource),
+	}
+	r.mu.Unlock()
+
+	r.joinGroup(universe)
+	return nil
+}
+
+// Subscribe has the handler called with the data of every packet received for the universe,
+// rather than merging it into our output
+func (r *SACNReceiver) Subscribe(universe uint16, handler func(data []byte)) error {
+	r.mu.Lock()
+	_, listening := r.universes[universe]
+	_, subscribed := r.handlers[universe]
+	if listening || subscribed {
+		r.mu.Unlock()
+		return fmt.Errorf("already listening to universe %d", universe)
+	}
+	r.handlers[universe] = handler
+	r.mu.Unlock()
+
+	r.joinGroup(universe)
+	return nil
+}
+
+func (r *SACNReceiver) joinGroup(universe uint16) {
+	var group [4]byte
+	copy(group[:], multicastAddress(universe).IP.To4())
+
+	raw, err := r.conn.SyscallConn()
+	if err != nil {
+		log.Printf("Warning: sACN input for universe %d is unicast only: %v", universe, err)
+		return
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = joinMulticastGroup(fd, group, r.iface)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		// unicast still reaches us
+		log.Printf("Warning: sACN input for universe %d is unicast only: %v", universe, err)
+	}
+}
+
+func (r *SACNReceiver) receiveLoop() {
+	defer r.wg.Done()
+
+	buffer := make([]byte, MaxPacketSize)
+	for {
+		n, addr, err := r.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error receiving sacn: %v", err)
+			continue
+		}
+		r.handlePacket(buffer[:n], addr)
+	}
+}
+
+func (r *SACNReceiver) handlePacket(packet []byte, addr *net.UDPAddr) {
+	// only data packets carrying plain DMX are merged. sync packets are ignored, input is
+	// merged into our next frame either way.
+	if len(packet) < HeaderLength ||
+		!bytes.Equal(packet[4:16], []byte("ASC-E1.17\000\000\000")) ||
+		binary.BigEndian.Uint32(packet[18:22]) != RootVector ||
+		binary.BigEndian.Uint32(packet[40:44]) != FramingVector ||
+		packet[117] != DMPVector ||
+		packet[125] != DefaultStartCode {
+		return
+	}
+
+	var cid [16]byte
+	copy(cid[:], packet[22:38])
+	if cid == r.ignoreCID {
+		return
+	}
+
+	options := packet[112]
+	if options&(1<<PreviewOptionBit) != 0 {
+		return
+	}
+
+	length := min(int(binary.BigEndian.Uint16(packet[123:125]))-1, len(packet)-HeaderLength, UniverseChannels)
+	if length < 0 {
+		return
+	}
+
+	number := binary.BigEndian.Uint16(packet[113:115])
+	r.mu.Lock()
+	if handler, subscribed := r.handlers[number]; subscribed {
+		r.mu.Unlock()
+		if options&(1<<StreamTerminateOptionsBit) == 0 {
+			handler(packet[HeaderLength : HeaderLength+length])
+		}
+		return
+	}
+	defer r.mu.Unlock()
+
+	universe, exists := r.universes[number]
+	if !exists {
+		return
+	}
+
+	source, known := universe.sources[cid]
+	if options&(1<<StreamTerminateOptionsBit) != 0 {
+		if known {
+			log.Printf("sACN source %s stopped sending universe %d", source.name, universe.number)
+			delete(universe.sources, cid)
+		}
+		return
+	}
+
+	now := time.Now()
+	sequence := packet[111]
+	if !known {
+		source = &inputSource{
+			cid:     cid,
+			name:    cString(packet[44:108]),
+			address: addr.IP.String(),
+		}
+		universe.sources[cid] = source
+		log.Printf("sACN source %s (%s) started sending universe %d", source.name, source.address, universe.number)
+	} else if diff := int8(sequence - source.sequence); diff <= 0 && diff > -20 {
+		// out of order or repeated, as E1.31 defines it
+		return
+	}
+
+	source.sequence = sequence
+	source.priority = packet[108]
+	source.lastReceived = now
+	for i := range length {
+		if !known || source.data[i] != packet[HeaderLength+i] {
+			source.data[i] = packet[HeaderLength+i]
+			source.changed[i] = now
+		}
+	}
+	for i := length; i < source.length; i++ {
+		source.data[i] = 0
+		source.changed[i] = now
+	}
+	source.length = length
+}
+
+// Merge combines the data we generated for a universe with whatever is being received for
+// it, using the merge policy. the merge is written over data, which is returned unchanged
+// for universes nobody is sending.
+func (r *SACNReceiver) Merge(universeNumber uint16, data []byte) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	universe, exists := r.universes[universeNumber]
+	if !exists {
+		return data
+	}
+
+	now := time.Now()
+	for i := range min(len(data), UniverseChannels) {
+		if universe.local[i] != data[i] {
+			universe.local[i] = data[i]
+			universe.localChanged[i] = now
+		}
+	}
+
+	sources := universe.active[:0]
+	for cid, source := range universe.sources {
+		if now.Sub(source.lastReceived) > SourceTimeout {
+			log.Printf("sACN source %s timed out on universe %d", source.name, universe.number)
+			delete(universe.sources, cid)
+			continue
+		}
+		sources = append(sources, source)
+	}
+	universe.active = sources
+	if len(sources) == 0 {
+		return data
+	}
+
+	merged := data
+	for _, source := range sources {
+		if source.length > len(merged) {
+			merged = append(merged, make([]byte, source.length-len(merged))...)
+		}
+	}
+
+	switch r.policy {
+	case MERGE_LTP:
+		for i := range merged {
+			latest := universe.localChanged[i]
+			for _, source := range sources {
+				if i < source.length && source.changed[i].After(latest) {
+					merged[i] = source.data[i]
+					latest = source.changed[i]
+				}
+			}
+		}
+
+	case MERGE_PRIORITY:
+		highest := universe.priority
+		for _, source := range sources {
+			highest = max(highest, source.priority)
+		}
+		if highest > universe.priority {
+			// our own output has been outranked
+			clear(merged)
+		}
+		for _, source := range sources {
+			if source.priority == highest {
+				mergeHighest(merged, source)
+			}
+		}
+
+	default:
+		for _, source := range sources {
+			mergeHighest(merged, source)
+		}
+	}
+	return merged
+}
+
+func mergeHighest(merged []byte, source *inputSource) {
+	for i := range source.length {
+		merged[i] = max(merged[i], source.data[i])
+	}
+}
+
+// Sources returns the sources currently sending each universe
+func (r *SACNReceiver) Sources() []InputSourceInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sources := []InputSourceInfo{}
+	for _, universe := range r.universes {
+		for _, source := range universe.sources {
+			if time.Since(source.lastReceived) > SourceTimeout {
+				continue
+			}
+			sources = append(sources, InputSourceInfo{
+				Universe:     universe.number,
+				Name:         source.name,
+				Address:      source.address,
+				Priority:     source.priority,
+				Channels:     source.length,
+				LastReceived: source.lastReceived,
+			})
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Universe != sources[j].Universe {
+			return sources[i].Universe < sources[j].Universe
+		}
+		return sources[i].Name < sources[j].Name
+	})
+	return sources
+}
+
+func (r *SACNReceiver) Close() error {
+	err := r.conn.Close()
+	r.wg.Wait()
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// E1.31 receivers can subscribe to a universe's multicast group instead of being sent it
+// directly, so any number of controllers and monitoring tools can listen at once. universe
+// N goes to 239.255.hi.lo, hi and lo being the two bytes of the universe number.
+
+const DefaultMulticastTTL = 1
+
+func multicastAddress(universe uint16) *net.UDPAddr {
+	return &net.UDPAddr{
+		IP:   net.IPv4(239, 255, byte(universe>>8), byte(universe&0xFF)),
+		Port: SACNPort,
+	}
+}
+
+// returns the IPv4 address multicast is sent from, given an interface name or one of its
+// addresses. an empty name leaves the choice to the routing table.
+func multicastInterfaceAddress(name string) ([4]byte, error) {
+	var address [4]byte
+	if name == "" {
+		return address, nil
+	}
+
+	if ip := net.ParseIP(name).To4(); ip != nil {
+		copy(address[:], ip)
+		return address, nil
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return address, fmt.Errorf("unknown multicast interface %s: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return address, fmt.Errorf("failed to read addresses of %s: %w", name, err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			copy(address[:], ipNet.IP.To4())
+			return address, nil
+		}
+	}
+	return address, fmt.Errorf("interface %s has no IPv4 address", name)
+}
+
+// sets the interface and TTL multicast is sent with on the connection
+func configureMulticast(conn *net.UDPConn, interfaceName string, ttl int) error {
+	address, err := multicastInterfaceAddress(interfaceName)
+	if err != nil {
+		return err
+	}
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("failed to configure multicast: %w", err)
+	}
+
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = setMulticastOptions(fd, address, ttl)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure multicast: %w", err)
+	}
+	if sockErr != nil {
+		return fmt.Errorf("failed to configure multicast: %w", sockErr)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix && !windows
+
+package main
+
+import "errors"
+
+func setMulticastOptions(fd uintptr, address [4]byte, ttl int) error {
+	if address == [4]byte{} && ttl == DefaultMulticastTTL {
+		return nil
+	}
+	return errors.New("multicast options are not supported on this platform")
+}
+
+func joinMulticastGroup(fd uintptr, group [4]byte, address [4]byte) error {
+	return errors.New("joining multicast groups is not supported on this platform")
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+func setMulticastOptions(fd uintptr, address [4]byte, ttl int) error {
+	if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, ttl); err != nil {
+		return err
+	}
+	if address == [4]byte{} {
+		return nil
+	}
+	return syscall.SetsockoptInet4Addr(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, address)
+}
+
+func joinMulticastGroup(fd uintptr, group [4]byte, address [4]byte) error {
+	return syscall.SetsockoptIPMreq(int(fd), syscall.IPPROTO_IP, syscall.IP_ADD_MEMBERSHIP, &syscall.IPMreq{Multiaddr: group, Interface: address})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+// returns a transmitter sending universe 1 to a local socket nothing reads from
+func newTestTransmitter(tb testing.TB, syncUniverse uint16) (*Transmitter, *Universe) {
+	tb.Helper()
+
+	transmitter, err := NewTransmitter(TransmitterConfig{SourceName: "test"})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { transmitter.Close() })
+
+	if syncUniverse == 0 {
+		_, err = transmitter.Activate(1)
+	} else {
+		_, err = transmitter.ActivateSynchronized(1, syncUniverse)
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	receiver, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { receiver.Close() })
+
+	universe := transmitter.universes[1]
+	universe.destinations = []Destination{{Addr: receiver.LocalAddr().(*net.UDPAddr), Priority: DefaultPriority}}
+	universe.setData(make([]byte, UniverseChannels))
+	return transmitter, universe
+}
+
+func TestSendDoesNotAllocate(t *testing.T) {
+	transmitter, universe := newTestTransmitter(t, 2)
+	transmitter.mu.Lock()
+	defer transmitter.mu.Unlock()
+
+	send := func() {
+		transmitter.sendToDestinations(universe)
+		transmitter.sendSyncPackets()
+	}
+	send()
+
+	if allocs := testing.AllocsPerRun(100, send); allocs != 0 {
+		t.Errorf("sending a synchronized universe allocated %v times", allocs)
+	}
+}
+
+func BenchmarkCreatePacket(b *testing.B) {
+	transmitter, universe := newTestTransmitter(b, 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		transmitter.createPacket(universe)
+	}
+}
+
+func BenchmarkSendToDestinations(b *testing.B) {
+	transmitter, universe := newTestTransmitter(b, 0)
+	transmitter.mu.Lock()
+	defer transmitter.mu.Unlock()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		transmitter.sendToDestinations(universe)
+	}
+}
+
+func BenchmarkCreateSyncPacket(b *testing.B) {
+	transmitter, _ := newTestTransmitter(b, 2)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		transmitter.createSyncPacket(2)
+	}
+}
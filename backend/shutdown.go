@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// shutting down, whether from a signal or POST /shutdown, fades the lights out, stops
+// rendering and then closes the outputs. closing sACN sends every universe its stream
+// terminated packets, so receivers let go of it immediately instead of holding the last
+// frame until their own timeout.
+
+// ShutdownRequest is the optional body of POST /shutdown
+type ShutdownRequest struct {
+	Fade *int `json:"fade,omitempty"` // in milliseconds, defaults to SHUTDOWN_FADE_MS
+}
+
+type Shutdown struct {
+	defaultFade time.Duration
+	requests    chan time.Duration
+}
+
+func NewShutdown(defaultFade time.Duration) *Shutdown {
+	return &Shutdown{
+		defaultFade: defaultFade,
+		requests:    make(chan time.Duration, 1),
+	}
+}
+
+// Request asks for a shutdown with the given fade. it returns false when one has already
+// been requested.
+func (s *Shutdown) Request(fade time.Duration) bool {
+	select {
+	case s.requests <- fade:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until a shutdown is requested or a signal arrives, returning how long to fade
+func (s *Shutdown) Wait(signals <-chan os.Signal) time.Duration {
+	select {
+	case sig := <-signals:
+		log.Printf("Received %v, shutting down", sig)
+		return s.defaultFade
+	case fade := <-s.requests:
+		log.Printf("Shutdown requested")
+		return fade
+	}
+}
+
+// Run fades out, stops the controller and closes everything receiving or sending frames
+func (s *Shutdown) Run(fade time.Duration, controller *PixelController, outputs *OutputHandler, inputs []io.Closer) {
+	if fade > 0 {
+		log.Printf("Fading out over %v", fade)
+		controller.FadeOut(fade)
+	}
+	controller.Stop()
+	if _, recording := controller.Recording(); recording {
+		if _, err := controller.StopRecording(); err != nil {
+			log.Printf("Error finishing recording: %v", err)
+		}
+	}
+
+	for _, input := range inputs {
+		if err := input.Close(); err != nil {
+			log.Printf("Error closing input: %v", err)
+		}
+	}
+	if err := outputs.Close(); err != nil {
+		log.Printf("Error closing outputs: %v", err)
+	}
+	log.Printf("Shutdown complete")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// patterns normally see the installation as points in space, but the pixels are physically
+// chained together along strips. a strip is an ordered run of pixels in wiring order, so 1D
+// effects like chasers and comets can travel along a leg or a tusk instead of a screen axis.
+
+type StripDirection string
+
+const (
+	STRIP_FORWARD StripDirection = "forward"
+	STRIP_REVERSE StripDirection = "reverse"
+)
+
+// StripConfig groups one or more segments into a single strip in the layout file
+type StripConfig struct {
+	Name      string         `json:"name"`
+	Segments  []string       `json:"segments"`
+	Direction StripDirection `json:"direction,omitempty"`
+}
+
+type Strip struct {
+	name      string
+	index     int
+	direction StripDirection
+	segments  []string
+	pixels    []*Pixel // in wiring order
+}
+
+func (s *Strip) Len() int {
+	return len(s.pixels)
+}
+
+// returns the pixel at the given position along the strip, honoring the strip's direction
+func (s *Strip) At(position int) *Pixel {
+	if s.direction == STRIP_REVERSE {
+		return s.pixels[len(s.pixels)-1-position]
+	}
+	return s.pixels[position]
+}
+
+// StripRenderer is implemented by 1D patterns, which draw each strip independently
+type StripRenderer interface {
+	RenderStrip(strip *Strip)
+}
+
+func renderStrips(pixelMap *PixelMap, renderer StripRenderer) {
+	for i := range pixelMap.strips {
+		renderer.RenderStrip(&pixelMap.strips[i])
+	}
+}
+
+// wraps a position along a strip into [0, length), from either direction
+func wrapPosition(position float64, length int) float64 {
+	return math.Mod(math.Mod(position, float64(length))+float64(length), float64(length))
+}
+
+// builds the strips declared in the layout. any segment not claimed by a declared strip
+// becomes a strip of its own.
+func buildStrips(pixelMap *PixelMap, configs []StripConfig) ([]Strip, error) {
+	strips := []Strip{}
+	claimed := make(map[string]bool)
+
+	for _, config := range configs {
+		direction := config.Direction
+		if direction == "" {
+			direction = STRIP_FORWARD
+		}
+		if direction != STRIP_FORWARD && direction != STRIP_REVERSE {
+			return nil, fmt.Errorf("strip %s: unknown direction %q", config.Name, direction)
+		}
+
+		strip := Strip{
+			name:      config.Name,
+			index:     len(strips),
+			direction: direction,
+			segments:  config.Segments,
+		}
+		for _, name := range config.Segments {
+			index, err := pixelMap.findSegment(name)
+			if err != nil {
+				return nil, fmt.Errorf("strip %s: %w", config.Name, err)
+			}
+			if claimed[name] {
+				return nil, fmt.Errorf("strip %s: segment %s already belongs to another strip", config.Name, name)
+			}
+			claimed[name] = true
+			strip.pixels = append(strip.pixels, segmentPixelsInWiringOrder(pixelMap, pixelMap.segments[index])...)
+		}
+		strips = append(strips, strip)
+	}
+
+	for _, segment := range pixelMap.segments {
+		if claimed[segment.config.Name] {
+			continue
+		}
+		strips = append(strips, Strip{
+			name:      segment.config.Name,
+			index:     len(strips),
+			direction: STRIP_FORWARD,
+			segments:  []string{segment.config.Name},
+			pixels:    segmentPixelsInWiringOrder(pixelMap, segment),
+		})
+	}
+
+	return strips, nil
+}
+
+func segmentPixelsInWiringOrder(pixelMap *PixelMap, segment PixelSegment) []*Pixel {
+	pixels := make([]*Pixel, 0, segment.count)
+	for i := segment.start; i < segment.start+segment.count; i++ {
+		pixels = append(pixels, &(*pixelMap.pixels)[i])
+	}
+	sort.SliceStable(pixels, func(i, j int) bool {
+		if pixels[i].universe != pixels[j].universe {
+			return pixels[i].universe < pixels[j].universe
+		}
+		return pixels[i].channelPosition < pixels[j].channelPosition
+	})
+	return pixels
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+)
+
+// WLED nodes take realtime frames over UDP, RGB triples behind a protocol byte and a timeout
+// byte, the seconds the node waits after the last packet before going back to its own
+// effects. nodes of up to WLEDMaxDRGBPixels get every frame in a single DRGB packet, longer
+// ones get DNRGB packets that each carry the index of their first pixel.
+
+const (
+	WLEDPort              = 21324
+	WLEDProtocolDRGB      = uint8(2)
+	WLEDProtocolDNRGB     = uint8(4)
+	WLEDDRGBHeaderLength  = 2
+	WLEDDNRGBHeaderLength = 4
+	WLEDMaxDRGBPixels     = 490
+	WLEDMaxDNRGBPixels    = 489
+	WLEDMaxPixels         = 0xFFFF + WLEDMaxDNRGBPixels
+	WLEDMaxPacketSize     = WLEDDRGBHeaderLength + WLEDMaxDRGBPixels*3
+	WLEDDefaultTimeout    = uint8(2)
+	WLEDNoTimeout         = uint8(255) // the node stays in realtime mode until it's rebooted
+)
+
+// WLEDConfig sends segments to a WLED node, laid out one after another in the order they're
+// listed, every pixel as RGB whatever its type
+type WLEDConfig struct {
+	Name     string   `json:"name"`
+	Address  string   `json:"address"` // host:port, the port defaults to 21324
+	Segments []string `json:"segments"`
+	Timeout  *uint8   `json:"timeout,omitempty"` // in seconds, 2 by default and 255 for never
+}
+
+// WLEDNodeInfo describes what a WLED node is sent
+type WLEDNodeInfo struct {
+	Name     string   `json:"name"`
+	Address  string   `json:"address"`
+	Segments []string `json:"segments"`
+	Pixels   int      `json:"pixels"`
+	Protocol string   `json:"protocol"`
+	Timeout  uint8    `json:"timeout"`
+}
+
+type WLEDTransmitter struct {
+	streams *deviceStreams
+}
+
+type WLEDNode struct {
+	deviceStream
+	timeout uint8
+	packet  []byte // reused for every packet sent
+}
+
+func NewWLEDTransmitter() (*WLEDTransmitter, error) {
+	streams, err := newDeviceStreams("wled node")
+	if err != nil {
+		return nil, err
+	}
+	return &WLEDTransmitter{streams: streams}, nil
+}
+
+func (t *WLEDTransmitter) Activate(name string, address string, timeout uint8) (*FrameSlot, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, fmt.Sprint(WLEDPort))
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", address, err)
+	}
+
+	return t.streams.add(&WLEDNode{
+		deviceStream: deviceStream{name: name, addr: udpAddr},
+		timeout:      timeout,
+		packet:       make([]byte, WLEDMaxPacketSize),
+	})
+}
+
+// writes a DRGB packet carrying every pixel to the start of the buffer
+func createDRGBPacket(buffer []byte, data []byte, timeout uint8) []byte {
+	packet := buffer[:WLEDDRGBHeaderLength+len(data)]
+	packet[0] = WLEDProtocolDRGB
+	packet[1] = timeout
+	copy(packet[WLEDDRGBHeaderLength:], data)
+	return packet
+}
+
+// writes a DNRGB packet carrying the pixels from the start index to the start of the buffer
+func createDNRGBPacket(buffer []byte, data []byte, start int, timeout uint8) []byte {
+	packet := buffer[:WLEDDNRGBHeaderLength+len(data)]
+	packet[0] = WLEDProtocolDNRGB
+	packet[1] = timeout
+	binary.BigEndian.PutUint16(packet[2:4], uint16(start))
+	copy(packet[WLEDDNRGBHeaderLength:], data)
+	return packet
+}
+
+func (node *WLEDNode) sendFrame(conn *net.UDPConn) {
+	pixels := len(node.data) / 3
+	if pixels == 0 {
+		return
+	}
+
+	if pixels <= WLEDMaxDRGBPixels {
+		node.sendPacket(conn, createDRGBPacket(node.packet, node.data[:pixels*3], node.timeout))
+		return
+	}
+	for start := 0; start < pixels; start += WLEDMaxDNRGBPixels {
+		end := min(start+WLEDMaxDNRGBPixels, pixels)
+		if !node.sendPacket(conn, createDNRGBPacket(node.packet, node.data[start*3:end*3], start, node.timeout)) {
+			return
+		}
+	}
+}
+
+func (node *WLEDNode) sendPacket(conn *net.UDPConn, packet []byte) bool {
+	if _, err := conn.WriteToUDP(packet, node.addr); err != nil {
+		log.Printf("Error sending to wled node %s: %v", node.name, err)
+		return false
+	}
+	return true
+}
+
+func (t *WLEDTransmitter) Close() error {
+	return t.streams.Close()
+}
+
+// returns the names of every segment sent to a DDP device or WLED node rather than through
+// a universe
+func deviceSegments(ddp []DDPConfig, wled []WLEDConfig) map[string]bool {
+	segments := ddpSegments(ddp)
+	for _, node := range wled {
+		for _, name := range node.Segments {
+			segments[name] = true
+		}
+	}
+	return segments
+}
+
+func (node WLEDConfig) timeout() uint8 {
+	if node.Timeout == nil {
+		return WLEDDefaultTimeout
+	}
+	return *node.Timeout
+}
+
+// checks the WLED nodes, whose names share the namespace of DDP devices and OPC servers and
+// whose segments can't also be sent over DDP
+func (p *PixelMap) checkWLED() error {
+	names := make(map[string]bool)
+	for _, device := range p.ddp {
+		names[device.Name] = true
+	}
+	for _, server := range p.opc {
+		names[server.Name] = true
+	}
+
+	claimed := make(map[string]string)
+	for _, device := range p.ddp {
+		for _, name := range device.Segments {
+			claimed[name] = device.Name
+		}
+	}
+
+	for _, node := range p.wled {
+		if node.Name == "" || node.Address == "" {
+			return fmt.Errorf("wled nodes need a name and an address")
+		}
+		if names[node.Name] {
+			return fmt.Errorf("wled node %s: name is already used by another output", node.Name)
+		}
+		names[node.Name] = true
+
+		if len(node.Segments) == 0 {
+			return fmt.Errorf("wled node %s has no segments", node.Name)
+		}
+		for _, name := range node.Segments {
+			if _, err := p.findSegment(name); err != nil {
+				return fmt.Errorf("wled node %s: %w", node.Name, err)
+			}
+			if other, exists := claimed[name]; exists {
+				return fmt.Errorf("wled node %s: segment %s is already sent to %s", node.Name, name, other)
+			}
+			claimed[name] = node.Name
+		}
+
+		if pixels := len(p.wledPixels(node)); pixels > WLEDMaxPixels {
+			return fmt.Errorf("wled node %s: %d pixels is more than DNRGB can address", node.Name, pixels)
+		}
+	}
+	return nil
+}
+
+// returns the pixels of a WLED node in the order they're sent
+func (p *PixelMap) wledPixels(node WLEDConfig) []*Pixel {
+	pixels := []*Pixel{}
+	for _, name := range node.Segments {
+		index, err := p.findSegment(name)
+		if err != nil {
+			continue
+		}
+		pixels = append(pixels, segmentPixelsInWiringOrder(p, p.segments[index])...)
+	}
+	return pixels
+}
+
+func (p *PixelMap) wledNodeInfo(node WLEDConfig) WLEDNodeInfo {
+	pixels := len(p.wledPixels(node))
+	protocol := "drgb"
+	if pixels > WLEDMaxDRGBPixels {
+		protocol = "dnrgb"
+	}
+	return WLEDNodeInfo{
+		Name:     node.Name,
+		Address:  node.Address,
+		Segments: node.Segments,
+		Pixels:   pixels,
+		Protocol: protocol,
+		Timeout:  node.timeout(),
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// a zone runs its own pattern and color mask on the pixels of one section, drawn over
+// whatever the main pattern renders everywhere else. each zone is a PixelController of its
+// own rendering into a private copy of the section's pixels, so it gets the same transitions,
+// parameter updates and geometry handling as the whole sculpture. a new zone starts out
+// showing the main pattern and transitions to its own from there; removing a zone
+// transitions back before it's dropped.
+
+var (
+	ErrSectionNotFound = NewError("section not found")
+	ErrZoneNotFound    = NewError("zone not found")
+)
+
+type Zone struct {
+	section    Section
+	controller *PixelController
+	colorMasks map[string]ColorMaskPattern
+	parent     *PatternInheritance
+	indexes    []int // index in the main pixel map of each of the zone's pixels
+	releasing  bool
+}
+
+// ZoneInfo describes a zone for the API
+type ZoneInfo struct {
+	Section             string               `json:"section"`
+	Label               string               `json:"label"`
+	Pixels              int                  `json:"pixels"`
+	Pattern             string               `json:"pattern"`
+	PatternParameters   AdjustableParameters `json:"patternParameters,omitempty"`
+	ColorMask           string               `json:"colorMask,omitempty"`
+	ColorMaskParameters AdjustableParameters `json:"colorMaskParameters,omitempty"`
+	Releasing           bool                 `json:"releasing,omitempty"`
+}
+
+type zoneSet struct {
+	zones map[string]*Zone
+	order []string // creation order, later zones draw over earlier ones
+	mu    sync.Mutex
+}
+
+// builds a pixel map holding copies of the pixels in the given section, along with the
+// index of each one in the original map. strips are narrowed down to the section's pixels.
+func (p *PixelMap) sectionMap(section string) (*PixelMap, []int) {
+	pixels := []Pixel{}
+	indexes := []int{}
+	sections := make(map[string]Section)
+
+	for i, pixel := range *p.pixels {
+		if !pixel.inSection(section) {
+			continue
+		}
+		pixels = append(pixels, pixel)
+		indexes = append(indexes, i)
+		for _, s := range pixel.sections {
+			sections[s.name] = s
+		}
+	}
+
+	sectionMap := &PixelMap{
+		pixels:   &pixels,
+		sections: sections,
+		geometry: Geometry{fixedOrigin: p.geometry.fixedOrigin, originX: p.geometry.originX, originY: p.geometry.originY},
+	}
+
+	positions := make(map[*Pixel]int, len(indexes))
+	for i, index := range indexes {
+		positions[&(*p.pixels)[index]] = i
+	}
+	for _, strip := range p.strips {
+		narrowed := Strip{
+			name:      strip.name,
+			index:     len(sectionMap.strips),
+			direction: strip.direction,
+			segments:  strip.segments,
+		}
+		for _, pixel := range strip.pixels {
+			if i, ok := positions[pixel]; ok {
+				narrowed.pixels = append(narrowed.pixels, &pixels[i])
+			}
+		}
+		if len(narrowed.pixels) > 0 {
+			sectionMap.strips = append(sectionMap.strips, narrowed)
+		}
+	}
+
+	sectionMap.computeGeometry()
+	sectionMap.narrowUniverseIndexes(p, indexes)
+	return sectionMap, indexes
+}
+
+// keeps the pixels of the source map's universes that are in this map, given the index in
+// the source of each of this map's pixels
+func (p *PixelMap) narrowUniverseIndexes(source *PixelMap, indexes []int) {
+	positions := make(map[int]int, len(indexes))
+	for i, index := range indexes {
+		positions[index] = i
+	}
+
+	p.universeIndexes = make(map[uint16][]int)
+	for universe, sourceIndexes := range source.universeIndexes {
+		for _, index := range sourceIndexes {
+			if i, ok := positions[index]; ok {
+				p.universeIndexes[universe] = append(p.universeIndexes[universe], i)
+			}
+		}
+	}
+}
+
+func (p *Pixel) inSection(name string) bool {
+	for _, section := range p.sections {
+		if section.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func newZone(pc *PixelController, section Section) (*Zone, error) {
+	pixelMap, indexes := pc.pixelMap.sectionMap(section.name)
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("section %s has no pixels", section.name)
+	}
+
+	// zone pixels start from what's currently displayed so the first transition is smooth
+	for i, index := range indexes {
+		(*pixelMap.pixels)[i].color = (*pc.pixelMap.pixels)[index].color
+	}
+
+	parent := &PatternInheritance{
+		BasePattern: BasePattern{Label: "Main Pattern"},
+		source:      pc.pixelMap,
+		pixelMap:    pixelMap,
+		indexes:     indexes,
+	}
+
+	controller := &PixelController{
+		errorTracker:     pc.errorTracker,
+		updateInterval:   pc.updateInterval,
+		stopChan:         make(chan struct{}),
+		currentPattern:   parent,
+		pixelMap:         pixelMap,
+		options:          pc.options.share(),
+		patternChange:    make(chan Pattern, 1),
+		colorMaskChange:  make(chan ColorMaskPattern, 1),
+		currentColorMask: getDefaultColorMask(pixelMap),
+		patterns:         registerPatterns(pixelMap),
+	}
+	controller.applyOriginOptions()
+
+	return &Zone{
+		section:    section,
+		controller: controller,
+		colorMasks: registerColorMasks(pixelMap),
+		parent:     parent,
+		indexes:    indexes,
+	}, nil
+}
+
+func (z *Zone) info() ZoneInfo {
+	info := ZoneInfo{
+		Section:   z.section.name,
+		Label:     z.section.label,
+		Pixels:    len(z.indexes),
+		Releasing: z.releasing,
+	}
+	if pattern := z.controller.currentPattern; pattern != nil {
+		info.Pattern = pattern.GetName()
+		if request := pattern.GetPatternUpdateRequest(); request != nil {
+			info.PatternParameters = request.GetParameters()
+		}
+	}
+	if mask := z.controller.currentColorMask; mask != nil {
+		info.ColorMask = mask.GetName()
+		info.ColorMaskParameters = mask.GetPatternUpdateRequest().GetParameters()
+	}
+	return info
+}
+
+// the zone has finished transitioning back to the main pattern and can be dropped
+func (z *Zone) released() bool {
+	return z.releasing && z.controller.transition == nil && z.controller.currentPattern == z.parent
+}
+
+// copies positions from the main map after a layout edit, keeping the zone's colors
+func (z *Zone) syncLayout(source *PixelMap) {
+	pixels := *z.controller.pixelMap.pixels
+	for i, index := range z.indexes {
+		color := pixels[i].color
+		pixels[i] = (*source.pixels)[index]
+		pixels[i].color = color
+	}
+	z.controller.pixelMap.computeGeometry()
+	z.controller.pixelMap.narrowUniverseIndexes(source, z.indexes)
+}
+
+// Zone returns the zone running on the given section, creating it if there isn't one yet
+func (pc *PixelController) Zone(section string) (*Zone, error) {
+	pc.layoutMutex.RLock()
+	defer pc.layoutMutex.RUnlock()
+	pc.zones.mu.Lock()
+	defer pc.zones.mu.Unlock()
+
+	if zone, exists := pc.zones.zones[section]; exists {
+		// asking for a zone that's being removed keeps it instead
+		zone.releasing = false
+		return zone, nil
+	}
+
+	s, exists := pc.pixelMap.sections[section]
+	if !exists {
+		return nil, ErrSectionNotFound
+	}
+
+	zone, err := newZone(pc, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if pc.zones.zones == nil {
+		pc.zones.zones = make(map[string]*Zone)
+	}
+	pc.zones.zones[section] = zone
+	pc.zones.order = append(pc.zones.order, section)
+
+	log.Printf("Zone created for section %s with %d pixels", section, len(zone.indexes))
+	return zone, nil
+}
+
+// Zones describes every zone, in the order they're drawn
+func (pc *PixelController) Zones() []ZoneInfo {
+	pc.zones.mu.Lock()
+	defer pc.zones.mu.Unlock()
+
+	zones := make([]ZoneInfo, 0, len(pc.zones.order))
+	for _, section := range pc.zones.order {
+		zones = append(zones, pc.zones.zones[section].info())
+	}
+	return zones
+}
+
+// RemoveZone hands the section back to the main pattern, transitioning if enabled
+func (pc *PixelController) RemoveZone(section string) error {
+	pc.zones.mu.Lock()
+	defer pc.zones.mu.Unlock()
+
+	zone, exists := pc.zones.zones[section]
+	if !exists {
+		return ErrZoneNotFound
+	}
+	if zone.controller.currentPattern == zone.parent {
+		zone.releasing = true
+		return nil
+	}
+
+	if err := zone.controller.SetPattern(zone.parent); err != nil {
+		return err
+	}
+	zone.releasing = true
+	return nil
+}
+
+// renders every zone over the main pattern's output
+func (pc *PixelController) renderZones() {
+	pc.zones.mu.Lock()
+	defer pc.zones.mu.Unlock()
+
+	remaining := pc.zones.order[:0]
+	for _, section := range pc.zones.order {
+		zone := pc.zones.zones[section]
+		zone.controller.renderFrame()
+
+		pixels := *zone.controller.pixelMap.pixels
+		for i, index := range zone.indexes {
+			(*pc.pixelMap.pixels)[index].color = pixels[i].color
+		}
+
+		if zone.released() {
+			delete(pc.zones.zones, section)
+			log.Printf("Zone for section %s removed", section)
+			continue
+		}
+		remaining = append(remaining, section)
+	}
+	pc.zones.order = remaining
+}
+
+// called with the layout locked after the main map's pixels have moved
+func (pc *PixelController) syncZoneLayouts() {
+	pc.zones.mu.Lock()
+	defer pc.zones.mu.Unlock()
+
+	for _, zone := range pc.zones.zones {
+		zone.syncLayout(pc.pixelMap)
+	}
+}
+
+func (pc *PixelController) updateZoneOptions(options *Options) {
+	pc.zones.mu.Lock()
+	defer pc.zones.mu.Unlock()
+
+	for _, zone := range pc.zones.zones {
+		zone.controller.UpdateOptions(options.share())
+	}
+}
+
+// PatternInheritance is the pattern a zone shows while it isn't running one of its own.
+// it copies the main pattern's colors, which have already been drawn for the frame.
+type PatternInheritance struct {
+	BasePattern
+	source   *PixelMap
+	pixelMap *PixelMap
+	indexes  []int
+}
+
+func (p *PatternInheritance) Update() {
+	for i, index := range p.indexes {
+		(*p.pixelMap.pixels)[i].color = (*p.source.pixels)[index].color
+	}
+}
+
+func (p *PatternInheritance) GetName() string {
+	return "main"
+}
+
+func (p *PatternInheritance) UpdateParameters(parameters AdjustableParameters) error {
+	return nil // nothing to adjust, the main pattern has the parameters
+}
+
+func (p *PatternInheritance) GetPatternUpdateRequest() PatternUpdateRequest {
+	return nil
+}
+
+func (p *PatternInheritance) TransitionFrom(source Pattern, progress float64) {
+	DefaultTransitionFromPattern(p, source, progress, p.pixelMap)
+}